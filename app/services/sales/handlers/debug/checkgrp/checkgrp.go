@@ -18,9 +18,12 @@ type Handlers struct {
 	Build string
 	Log   *zap.SugaredLogger
 	DB    *sqlx.DB
+	Drain *Drain
 }
 
 // Readiness checks if the database is ready and if not will return a 500 status.
+// Once the service has started draining it always reports failing, so the
+// load balancer stops routing traffic before the listener is shut down.
 // Do not respond by just returning an error because further up in the call
 // stack it will interpret that as a non-trusted error.
 func (h Handlers) Readiness(w http.ResponseWriter, r *http.Request) {
@@ -29,7 +32,11 @@ func (h Handlers) Readiness(w http.ResponseWriter, r *http.Request) {
 
 	status := "ok"
 	statusCode := http.StatusOK
-	if err := database.StatusCheck(ctx, h.DB); err != nil {
+	switch {
+	case h.Drain.Started():
+		status = "shutting down"
+		statusCode = http.StatusServiceUnavailable
+	case database.StatusCheck(ctx, h.DB) != nil:
 		status = "db not ready"
 		statusCode = http.StatusInternalServerError
 	}
@@ -0,0 +1,23 @@
+package checkgrp
+
+import "sync/atomic"
+
+// Drain tracks whether the service has started shutting down. Once started,
+// the readiness check reports failing so the load balancer stops routing new
+// traffic while in-flight requests are drained.
+type Drain struct {
+	started int32
+}
+
+// Start marks the service as draining. It is safe to call more than once.
+func (d *Drain) Start() {
+	atomic.StoreInt32(&d.started, 1)
+}
+
+// Started reports whether the service is draining.
+func (d *Drain) Started() bool {
+	if d == nil {
+		return false
+	}
+	return atomic.LoadInt32(&d.started) == 1
+}
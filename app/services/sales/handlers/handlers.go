@@ -4,12 +4,21 @@ import (
 	"expvar"
 	"github.com/jmoiron/sqlx"
 	"github.com/mohammadhsn/ultimate-service/app/services/sales/handlers/debug/checkgrp"
+	"github.com/mohammadhsn/ultimate-service/app/services/sales/handlers/v1/docgrp"
 	"github.com/mohammadhsn/ultimate-service/app/services/sales/handlers/v1/testgrp"
+	"github.com/mohammadhsn/ultimate-service/app/services/sales/handlers/v1/usergrp"
+	"github.com/mohammadhsn/ultimate-service/business/core/user"
+	"github.com/mohammadhsn/ultimate-service/business/data/store/idempotency"
+	userstore "github.com/mohammadhsn/ultimate-service/business/data/store/user"
+	"github.com/mohammadhsn/ultimate-service/business/sys/validate"
 	"github.com/mohammadhsn/ultimate-service/business/web/mid"
+	"github.com/mohammadhsn/ultimate-service/foundation/cache"
+	"github.com/mohammadhsn/ultimate-service/foundation/openapi"
 	"github.com/mohammadhsn/ultimate-service/foundation/web"
 	"net/http"
 	"net/http/pprof"
 	"os"
+	"time"
 
 	"go.uber.org/zap"
 )
@@ -29,38 +38,71 @@ func DebugStandardLibraryMux() *http.ServeMux {
 	return mux
 }
 
+// DebugMuxConfig contains all the mandatory systems required by the debug mux.
+type DebugMuxConfig struct {
+	Build    string
+	Log      *zap.SugaredLogger
+	LogLevel zap.AtomicLevel
+	DB       *sqlx.DB
+	Drain    *checkgrp.Drain
+}
+
 // DebugMux registers all the debug standard library and then custom
 // debug application routes for the service. This bypassing the yse of the
 // DefaultServerMux. Using the DefaultServerMux would be a security risk since
 // a dependency could inject a handler into our service without us knowing it.
-func DebugMux(build string, log *zap.SugaredLogger, db *sqlx.DB) http.Handler {
+func DebugMux(cfg DebugMuxConfig) http.Handler {
 	mux := DebugStandardLibraryMux()
 
 	cgh := checkgrp.Handlers{
-		Build: build,
-		Log:   log,
-		DB:    db,
+		Build: cfg.Build,
+		Log:   cfg.Log,
+		DB:    cfg.DB,
+		Drain: cfg.Drain,
 	}
 
 	mux.HandleFunc("/debug/readiness", cgh.Readiness)
 	mux.HandleFunc("/debug/liveness", cgh.Liveness)
 
+	// The atomic level serves GET and PUT requests for reading and changing
+	// the log level at runtime.
+	mux.Handle("/debug/loglevel", cfg.LogLevel)
+
 	return mux
 }
 
 // APIMuxConfig constructs a http.Handler with all application routes defined.
 type APIMuxConfig struct {
-	Shutdown chan os.Signal
-	Log      *zap.SugaredLogger
+	Build       string
+	Shutdown    chan os.Signal
+	Log         *zap.SugaredLogger
+	DB          *sqlx.DB
+	LogDebugKey string
+	CORS        mid.CORSConfig
+	RateLimit   mid.RateLimitConfig
+
+	// AdminKey is the bearer token of the admin routes, they are off when
+	// it is empty.
+	AdminKey string
+
+	// IdempotencyTTL is how long the responses of POST requests carrying an
+	// Idempotency-Key header are kept for replay.
+	IdempotencyTTL time.Duration
+
+	// UserCache holds the users looked up by ID, it may be nil.
+	UserCache *cache.Cache
 }
 
 func APIMux(cfg APIMuxConfig) *web.App {
 	// Construct the web.App which holds all routes.
 	app := web.NewApp(
 		cfg.Shutdown,
+		cfg.Log,
+		mid.Metrics(),
 		mid.Logger(cfg.Log),
+		mid.DebugLog(cfg.Log, cfg.LogDebugKey),
+		mid.CORS(cfg.CORS),
 		mid.Errors(cfg.Log),
-		mid.Metrics(),
 		mid.Panics(),
 	)
 
@@ -77,5 +119,55 @@ func v1(app *web.App, cfg APIMuxConfig) {
 		Log: cfg.Log,
 	}
 
-	app.Handle(http.MethodGet, version, "/test", tgh.Test)
+	g := app.Group(version,
+		mid.RateLimit(cfg.RateLimit),
+		mid.Idempotency(cfg.Log, mid.IdempotencyConfig{
+			Store:          idempotency.NewStore(cfg.Log, cfg.DB),
+			TTL:            cfg.IdempotencyTTL,
+			TrustedProxies: cfg.RateLimit.TrustedProxies,
+			Subject:        cfg.RateLimit.Subject,
+		}),
+	)
+
+	g.HandleDoc(http.MethodGet, "/test", web.Doc{Summary: "Test the error handling"}, tgh.Test)
+
+	ugh := usergrp.Handlers{
+		User: user.NewCore(cfg.Log, cfg.DB, cfg.UserCache),
+	}
+
+	g.HandleDoc(http.MethodPost, "/users", web.Doc{
+		Summary:  "Create a user",
+		Request:  userstore.NewUser{},
+		Response: userstore.User{},
+		Status:   http.StatusCreated,
+	}, ugh.Create)
+	g.HandleDoc(http.MethodGet, "/users/export", web.Doc{
+		Summary:  "Export all users, the admin key must be sent as a bearer token",
+		Response: []userstore.User{},
+	}, ugh.Export, mid.Admin(cfg.AdminKey))
+	g.HandleDoc(http.MethodGet, "/users/:id", web.Doc{
+		Summary:  "Get a user by ID",
+		Response: userstore.User{},
+	}, ugh.QueryById)
+	g.HandleDoc(http.MethodPut, "/users/:id", web.Doc{
+		Summary:  "Update a user, the If-Match header must carry its ETag",
+		Request:  userstore.UpdateUser{},
+		Response: userstore.User{},
+	}, ugh.Update)
+	g.HandleDoc(http.MethodDelete, "/users/:id", web.Doc{
+		Summary: "Delete a user",
+		Status:  http.StatusNoContent,
+	}, ugh.Delete)
+
+	// The document describes the routes registered above, so this must be
+	// the last route of the version.
+	dgh := docgrp.Handlers{
+		Doc: openapi.Generate(openapi.Config{
+			Title:      "Sales API",
+			Version:    cfg.Build,
+			ErrorModel: validate.ErrorResponse{},
+		}, app.Routes()),
+	}
+
+	g.Handle(http.MethodGet, "/openapi.json", dgh.OpenAPI)
 }
@@ -0,0 +1,20 @@
+// Package docgrp maintains the group of handlers for API documentation.
+package docgrp
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/mohammadhsn/ultimate-service/foundation/openapi"
+	"github.com/mohammadhsn/ultimate-service/foundation/web"
+)
+
+// Handlers manages the set of documentation endpoints.
+type Handlers struct {
+	Doc openapi.Document
+}
+
+// OpenAPI returns the OpenAPI document describing the API.
+func (h Handlers) OpenAPI(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	return web.Respond(ctx, w, h.Doc, http.StatusOK)
+}
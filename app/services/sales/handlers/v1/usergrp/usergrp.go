@@ -1,7 +1,150 @@
+// Package usergrp maintains the group of handlers for user access.
 package usergrp
 
-import "github.com/mohammadhsn/ultimate-service/business/core/user"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
 
+	"github.com/mohammadhsn/ultimate-service/business/core/user"
+	userstore "github.com/mohammadhsn/ultimate-service/business/data/store/user"
+	"github.com/mohammadhsn/ultimate-service/business/sys/database"
+	"github.com/mohammadhsn/ultimate-service/business/sys/validate"
+	"github.com/mohammadhsn/ultimate-service/foundation/web"
+)
+
+// Handlers manages the set of user endpoints.
 type Handlers struct {
 	User user.Core
 }
+
+// Create adds a new user to the system.
+func (h Handlers) Create(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, err := web.GetValues(ctx)
+	if err != nil {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	var nu userstore.NewUser
+	if err := web.Decode(r, &nu); err != nil {
+		return validate.NewRequestError(err, http.StatusBadRequest)
+	}
+
+	usr, err := h.User.Create(ctx, nu, v.Now)
+	if err != nil {
+		return fmt.Errorf("creating user: %w", err)
+	}
+
+	return web.Respond(ctx, w, usr, http.StatusCreated)
+}
+
+// QueryById returns a user by its ID. The ETag header carries the version of
+// the user, to be sent back in the If-Match header of updates, and allows
+// conditional requests together with the Last-Modified header.
+func (h Handlers) QueryById(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	id := web.Param(r, "id")
+
+	usr, err := h.User.QueryById(ctx, id)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrInvalidID):
+			return validate.NewRequestError(err, http.StatusBadRequest)
+		case errors.Is(err, database.ErrNotFound):
+			return validate.NewRequestError(err, http.StatusNotFound)
+		default:
+			return fmt.Errorf("ID[%s]: %w", id, err)
+		}
+	}
+
+	w.Header().Set("ETag", etag(usr))
+	w.Header().Set("Last-Modified", usr.DateUpdated.UTC().Format(http.TimeFormat))
+
+	return web.Respond(ctx, w, usr, http.StatusOK)
+}
+
+// Update modifies a user in the system. The If-Match header must carry the
+// ETag of the user the changes are based on, so concurrent updates don't
+// overwrite each other.
+func (h Handlers) Update(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, err := web.GetValues(ctx)
+	if err != nil {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	ifMatch := r.Header.Get("If-Match")
+	if ifMatch == "" {
+		return validate.NewRequestError(errors.New("If-Match header is required"), http.StatusPreconditionRequired)
+	}
+
+	var uu userstore.UpdateUser
+	if err := web.Decode(r, &uu); err != nil {
+		return validate.NewRequestError(err, http.StatusBadRequest)
+	}
+
+	id := web.Param(r, "id")
+
+	// The cached user may be older than the ETag of the client.
+	usr, err := h.User.QueryByIdFresh(ctx, id)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrInvalidID):
+			return validate.NewRequestError(err, http.StatusBadRequest)
+		case errors.Is(err, database.ErrNotFound):
+			return validate.NewRequestError(err, http.StatusNotFound)
+		default:
+			return fmt.Errorf("ID[%s]: %w", id, err)
+		}
+	}
+
+	if !web.MatchETag(ifMatch, etag(usr), false) {
+		return validate.NewRequestError(database.ErrVersionConflict, http.StatusPreconditionFailed)
+	}
+
+	usr, err = h.User.Update(ctx, id, uu, usr.Version, v.Now)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrNotFound):
+			return validate.NewRequestError(err, http.StatusNotFound)
+		case errors.Is(err, database.ErrVersionConflict):
+			return validate.NewRequestError(err, http.StatusPreconditionFailed)
+		default:
+			return fmt.Errorf("ID[%s] User[%+v]: %w", id, &uu, err)
+		}
+	}
+
+	w.Header().Set("ETag", etag(usr))
+
+	return web.Respond(ctx, w, usr, http.StatusOK)
+}
+
+// Delete removes a user from the system.
+func (h Handlers) Delete(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	id := web.Param(r, "id")
+
+	if err := h.User.Delete(ctx, id); err != nil {
+		switch {
+		case errors.Is(err, database.ErrInvalidID):
+			return validate.NewRequestError(err, http.StatusBadRequest)
+		default:
+			return fmt.Errorf("ID[%s]: %w", id, err)
+		}
+	}
+
+	return web.Respond(ctx, w, nil, http.StatusNoContent)
+}
+
+// Export streams every user to the client without holding them in memory.
+func (h Handlers) Export(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	return web.RespondStream(ctx, w, http.StatusOK, func(send func(item interface{}) error) error {
+		return h.User.QueryAll(ctx, func(usr userstore.User) error {
+			return send(usr)
+		})
+	})
+}
+
+// etag returns the entity tag of the user, which is its version.
+func etag(usr userstore.User) string {
+	return web.ETag(strconv.Itoa(usr.Version))
+}
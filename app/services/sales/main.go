@@ -13,64 +13,93 @@ import (
 
 	"github.com/ardanlabs/conf"
 	"github.com/mohammadhsn/ultimate-service/app/services/sales/handlers"
+	"github.com/mohammadhsn/ultimate-service/app/services/sales/handlers/debug/checkgrp"
+	"github.com/mohammadhsn/ultimate-service/business/data/store/idempotency"
 	"github.com/mohammadhsn/ultimate-service/business/sys/database"
+	"github.com/mohammadhsn/ultimate-service/business/web/mid"
+	"github.com/mohammadhsn/ultimate-service/foundation/cache"
 	"github.com/mohammadhsn/ultimate-service/foundation/logger"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/exporters/zipkin"
+	"go.opentelemetry.io/otel/propagation"
 	"go.opentelemetry.io/otel/sdk/resource"
 	"go.opentelemetry.io/otel/sdk/trace"
 	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 	//_ "go.uber.org/automaxprocs"
 )
 
 var build = "develop"
 
-func main() {
-	// Construct the application logger.
-	log, err := logger.New("SALES")
-	if err != nil {
-		fmt.Println(err)
-		os.Exit(1)
+// config holds the configuration of the service.
+type config struct {
+	conf.Version
+	Web struct {
+		APIHost         string        `conf:"default:0.0.0.0:3000"`
+		DebugHost       string        `conf:"default:0.0.0.0:4000"`
+		ReadTimout      time.Duration `conf:"default:5s"`
+		WriteTimeout    time.Duration `conf:"default:10s"`
+		IdleTimeout     time.Duration `conf:"default:120s"`
+		ShutdownTimeout time.Duration `conf:"default:20s"`
+		PreStopDelay    time.Duration `conf:"default:5s"`
+		AreYouOk        bool          `conf:"default:true"`
 	}
-
-	defer log.Sync()
-	// Perform the startup and shutdown sequence.
-	if err := run(log); err != nil {
-		log.Errorw("startup", "ERROR", err)
-		os.Exit(1)
+	CORS struct {
+		AllowedOrigins []string      `conf:"default:*"`
+		AllowedMethods []string      `conf:"default:GET;POST;PUT;PATCH;DELETE;OPTIONS"`
+		AllowedHeaders []string      `conf:"default:Accept;Authorization;Content-Type;If-Match;Idempotency-Key"`
+		MaxAge         time.Duration `conf:"default:1h"`
+	}
+	RateLimit struct {
+		Rate           float64  `conf:"default:10"`
+		Burst          int      `conf:"default:20"`
+		TrustedProxies []string `conf:"default:127.0.0.1/32;10.0.0.0/8"`
+	}
+	Admin struct {
+		Key string `conf:"mask"`
+	}
+	Idempotency struct {
+		TTL        time.Duration `conf:"default:24h"`
+		GCInterval time.Duration `conf:"default:1h"`
+	}
+	UserCache struct {
+		Size int           `conf:"default:1000"`
+		TTL  time.Duration `conf:"default:30s"`
+	}
+	DB struct {
+		User        string `conf:"default:postgres"`
+		Password    string `conf:"default:postgres,mask"`
+		Host        string `conf:"default:localhost"`
+		Name        string `conf:"default:postgres"`
+		MaxIdleCons int    `conf:"default:0"`
+		MaxOpenCons int    `conf:"default:0"`
+		DisableTLS  bool   `conf:"default:true"`
+	}
+	Log struct {
+		Level            string        `conf:"default:info"`
+		DebugKey         string        `conf:"mask"`
+		SampleInitial    int           `conf:"default:100"`
+		SampleThereafter int           `conf:"default:100"`
+		SampleTick       time.Duration `conf:"default:1s"`
+		Redact           []string      `conf:"default:password;password_confirm;authorization"`
+		Mask             []string      `conf:"default:email"`
+		FilePath         string
+		FileMaxSize      int  `conf:"default:100"`
+		FileMaxBackups   int  `conf:"default:3"`
+		ErrorsToStderr   bool `conf:"default:false"`
+	}
+	Zipkin struct {
+		ReporterURI string  `conf:"default:http://localhost:9411/api/v2/spans"`
+		ServiceName string  `conf:"default:sales"`
+		Probability float64 `conf:"default:0.05"`
 	}
 }
 
-func run(log *zap.SugaredLogger) error {
-	// Configuration
-	cfg := struct {
-		conf.Version
-		Web struct {
-			APIHost         string        `conf:"default:0.0.0.0:3000"`
-			DebugHost       string        `conf:"default:0.0.0.0:4000"`
-			ReadTimout      time.Duration `conf:"default:5s"`
-			WriteTimeout    time.Duration `conf:"default:10s"`
-			IdleTimeout     time.Duration `conf:"default:120s"`
-			ShutdownTimeout time.Duration `conf:"default:20s"`
-			AreYouOk        bool          `conf:"default:true"`
-		}
-		DB struct {
-			User        string `conf:"default:postgres"`
-			Password    string `conf:"default:postgres,mask"`
-			Host        string `conf:"default:localhost"`
-			Name        string `conf:"default:postgres"`
-			MaxIdleCons int    `conf:"default:0"`
-			MaxOpenCons int    `conf:"default:0"`
-			DisableTLS  bool   `conf:"default:true"`
-		}
-		Zipkin struct {
-			ReporterURI string  `conf:"default:http://localhost:9411/api/v2/spans"`
-			ServiceName string  `conf:"default:sales"`
-			Probability float64 `conf:"default:0.05"`
-		}
-	}{
+func main() {
+	// Parse the configuration first, it decides how the logger is built.
+	cfg := config{
 		Version: conf.Version{
 			SVN:  build,
 			Desc: "copyright stuff",
@@ -83,12 +112,48 @@ func run(log *zap.SugaredLogger) error {
 	if err != nil {
 		if errors.Is(err, conf.ErrHelpWanted) {
 			fmt.Println(help)
-			return nil
+			return
 		}
 
-		return fmt.Errorf("parsing config %w", err)
+		fmt.Println("parsing config:", err)
+		os.Exit(1)
+	}
+
+	lvl, err := zapcore.ParseLevel(cfg.Log.Level)
+	if err != nil {
+		fmt.Println("parsing log level:", err)
+		os.Exit(1)
+	}
+	level := zap.NewAtomicLevelAt(lvl)
+
+	// Construct the application logger.
+	log, err := logger.New(logger.Config{
+		Service:          "SALES",
+		Level:            level,
+		SampleInitial:    cfg.Log.SampleInitial,
+		SampleThereafter: cfg.Log.SampleThereafter,
+		SampleTick:       cfg.Log.SampleTick,
+		Redact:           cfg.Log.Redact,
+		Mask:             cfg.Log.Mask,
+		FilePath:         cfg.Log.FilePath,
+		FileMaxSize:      cfg.Log.FileMaxSize,
+		FileMaxBackups:   cfg.Log.FileMaxBackups,
+		ErrorsToStderr:   cfg.Log.ErrorsToStderr,
+	})
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	defer log.Sync()
+	// Perform the startup and shutdown sequence.
+	if err := run(log, level, cfg); err != nil {
+		log.Errorw("startup", "ERROR", err)
+		os.Exit(1)
 	}
+}
 
+func run(log *zap.SugaredLogger, level zap.AtomicLevel, cfg config) error {
 	// Application starting
 	log.Infow("starting service", "version", build)
 	defer log.Infow("shutdown complete")
@@ -124,8 +189,12 @@ func run(log *zap.SugaredLogger) error {
 	}
 
 	defer func() {
+		start := time.Now()
 		log.Infow("shutdown", "status", "stopping database support", "host", cfg.DB.Host)
-		db.Close()
+		if err := db.Close(); err != nil {
+			log.Errorw("shutdown", "status", "stopping database support", "ERROR", err)
+		}
+		log.Infow("shutdown", "status", "database support stopped", "duration", time.Since(start))
 	}()
 
 	// Start Tracing support
@@ -136,10 +205,30 @@ func run(log *zap.SugaredLogger) error {
 	if err != nil {
 		return fmt.Errorf("starting tracing %w", err)
 	}
-	defer traceProvider.Shutdown(context.Background())
+	defer func() {
+		start := time.Now()
+		log.Infow("shutdown", "status", "flushing trace provider")
+
+		ctx, cancel := context.WithTimeout(context.Background(), cfg.Web.ShutdownTimeout)
+		defer cancel()
+
+		if err := traceProvider.Shutdown(ctx); err != nil {
+			log.Errorw("shutdown", "status", "flushing trace provider", "ERROR", err)
+		}
+		log.Infow("shutdown", "status", "trace provider flushed", "duration", time.Since(start))
+	}()
+
+	// Readiness state shared between the debug mux and the shutdown sequence.
+	drain := new(checkgrp.Drain)
 
 	// Construct the mux for the debug calls.
-	debugMux := handlers.DebugMux(build, log, db)
+	debugMux := handlers.DebugMux(handlers.DebugMuxConfig{
+		Build:    build,
+		Log:      log,
+		LogLevel: level,
+		DB:       db,
+		Drain:    drain,
+	})
 
 	// Start the service listening for debug requests.
 	// Not concerned with shutting this down with load shedding.
@@ -154,11 +243,70 @@ func run(log *zap.SugaredLogger) error {
 	shutdown := make(chan os.Signal, 1)
 	signal.Notify(shutdown, syscall.SIGTERM, syscall.SIGKILL)
 
+	trustedProxies, err := mid.ParseTrustedProxies(cfg.RateLimit.TrustedProxies)
+	if err != nil {
+		return fmt.Errorf("parsing trusted proxies: %w", err)
+	}
+
+	// Requests carrying the admin key share a bucket of their own, every
+	// other client is told apart by its address.
+	rateLimit := mid.RateLimitConfig{
+		Rate:           cfg.RateLimit.Rate,
+		Burst:          cfg.RateLimit.Burst,
+		TrustedProxies: trustedProxies,
+		Subject:        mid.AdminSubject(cfg.Admin.Key),
+	}
+	if err := rateLimit.Validate(); err != nil {
+		return fmt.Errorf("configuring rate limit: %w", err)
+	}
+
+	// Cache the users looked up by ID, a size of 0 disables the cache. The
+	// statistics are published with the other metrics on the debug mux.
+	var userCache *cache.Cache
+	if cfg.UserCache.Size > 0 {
+		userCache = cache.New(cfg.UserCache.Size, cfg.UserCache.TTL)
+		expvar.Publish("user_cache", expvar.Func(func() interface{} { return userCache.Stats() }))
+	}
+
 	apiMux := handlers.APIMux(handlers.APIMuxConfig{
-		Shutdown: shutdown,
-		Log:      log,
+		Build:       build,
+		Shutdown:    shutdown,
+		Log:         log,
+		DB:          db,
+		LogDebugKey: cfg.Log.DebugKey,
+		CORS: mid.CORSConfig{
+			AllowedOrigins: cfg.CORS.AllowedOrigins,
+			AllowedMethods: cfg.CORS.AllowedMethods,
+			AllowedHeaders: cfg.CORS.AllowedHeaders,
+			MaxAge:         cfg.CORS.MaxAge,
+		},
+		RateLimit:      rateLimit,
+		AdminKey:       cfg.Admin.Key,
+		IdempotencyTTL: cfg.Idempotency.TTL,
+		UserCache:      userCache,
 	})
 
+	// Garbage collect the expired idempotency keys until the service stops.
+	gcCtx, stopGC := context.WithCancel(context.Background())
+	defer stopGC()
+
+	go func() {
+		store := idempotency.NewStore(log, db)
+		ticker := time.NewTicker(cfg.Idempotency.GCInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-gcCtx.Done():
+				return
+			case now := <-ticker.C:
+				if err := store.DeleteExpired(gcCtx, now); err != nil {
+					log.Errorw("idempotency", "status", "deleting expired keys", "ERROR", err)
+				}
+			}
+		}
+	}()
+
 	api := http.Server{
 		Addr:         cfg.Web.APIHost,
 		Handler:      apiMux,
@@ -180,19 +328,31 @@ func run(log *zap.SugaredLogger) error {
 	case err := <-serverErrors:
 		return fmt.Errorf("server error: %w", err)
 	case sig := <-shutdown:
-		log.Info("shutdown", "status", "shutdown started", "signal", sig)
-		defer log.Infow("shutdown", "status", "shutdown complete", "signal", sig)
+		log.Infow("shutdown", "status", "shutdown started", "signal", sig)
+
+		// Fail the readiness check first and give the load balancer time to
+		// stop routing new traffic to this instance.
+		start := time.Now()
+		drain.Start()
+		log.Infow("shutdown", "status", "readiness failing, waiting pre-stop delay", "delay", cfg.Web.PreStopDelay)
+		time.Sleep(cfg.Web.PreStopDelay)
+		log.Infow("shutdown", "status", "pre-stop delay complete", "duration", time.Since(start))
 
 		// Give outstanding requests a deadline for completion,
 		ctx, cancel := context.WithTimeout(context.Background(), cfg.Web.ShutdownTimeout)
 		defer cancel()
 
 		// Asking listener to shut down and shed load.
+		start = time.Now()
+		log.Infow("shutdown", "status", "draining in-flight requests")
 		if err := api.Shutdown(ctx); err != nil {
 			api.Close()
 			return fmt.Errorf("could not stop server gracefuly: %w", err)
 		}
+		log.Infow("shutdown", "status", "in-flight requests drained", "duration", time.Since(start))
 
+		// The deferred functions flush the trace provider and then close the
+		// database, in that order.
 	}
 
 	return nil
@@ -226,5 +386,8 @@ func startTracing(serviceName string, reporterUri string, probability float64) (
 
 	otel.SetTracerProvider(traceProvider)
 
+	// Continue the traces of callers sending a W3C traceparent header.
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
 	return traceProvider, nil
 }
@@ -0,0 +1,41 @@
+package tests
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/mohammadhsn/ultimate-service/app/services/sales/handlers"
+	"github.com/mohammadhsn/ultimate-service/app/services/sales/handlers/debug/checkgrp"
+	"github.com/mohammadhsn/ultimate-service/business/data/tests"
+	"go.uber.org/zap"
+)
+
+// TestChecks snapshots the responses of the check endpoints that need no
+// database. The readiness check is only answered without one while draining.
+func TestChecks(t *testing.T) {
+	var drain checkgrp.Drain
+	drain.Start()
+
+	debug := handlers.DebugMux(handlers.DebugMuxConfig{
+		Build: "test",
+		Log:   zap.NewNop().Sugar(),
+		Drain: &drain,
+	})
+	wt := tests.NewWebTest(t, debug, nil)
+
+	t.Log("Given the need to check the health of the service.")
+
+	t.Log("\tTest 0:\tWhen checking the liveness.")
+	{
+		wt.Get("/debug/liveness").Expect().
+			Status(http.StatusOK).
+			Golden("check_liveness", "host", "pod", "podIp", "node", "namespace")
+	}
+
+	t.Log("\tTest 1:\tWhen checking the readiness while draining.")
+	{
+		wt.Get("/debug/readiness").Expect().
+			Status(http.StatusServiceUnavailable).
+			Golden("check_readiness_draining")
+	}
+}
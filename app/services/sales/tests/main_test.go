@@ -0,0 +1,12 @@
+package tests
+
+import (
+	"os"
+	"testing"
+
+	"github.com/mohammadhsn/ultimate-service/business/data/tests"
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(tests.Main(m))
+}
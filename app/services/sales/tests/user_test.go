@@ -0,0 +1,126 @@
+package tests
+
+import (
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/mohammadhsn/ultimate-service/app/services/sales/handlers"
+	"github.com/mohammadhsn/ultimate-service/business/data/store/user"
+	"github.com/mohammadhsn/ultimate-service/business/data/tests"
+	"go.uber.org/zap"
+)
+
+// TestUserErrors exercises the error paths that are answered before any
+// database access, so it needs no database.
+func TestUserErrors(t *testing.T) {
+	api := handlers.APIMux(handlers.APIMuxConfig{
+		Shutdown: make(chan os.Signal, 1),
+		Log:      zap.NewNop().Sugar(),
+	})
+	wt := tests.NewWebTest(t, api, nil)
+
+	t.Log("Given the need to map errors to responses.")
+
+	tt := []struct {
+		name   string
+		req    *tests.Request
+		status int
+		error  string
+		golden string
+	}{
+		{"invalid id", wt.Get("/v1/users/123"), http.StatusBadRequest, "query: ID is not in its proper form", "user_invalid_id"},
+		{"unknown route", wt.Get("/v1/unknown"), http.StatusNotFound, "Not Found", "unknown_route"},
+		{"wrong method", wt.Request(http.MethodPatch, "/v1/users/123", nil), http.StatusMethodNotAllowed, "Method Not Allowed", "wrong_method"},
+		{"update without If-Match", wt.Put("/v1/users/"+tests.SeedUserID, user.UpdateUser{}), http.StatusPreconditionRequired, "If-Match header is required", "user_update_no_if_match"},
+		{"update with unknown field", wt.Put("/v1/users/"+tests.SeedUserID, `{"nickname":"gopher"}`).Header("If-Match", `"1"`), http.StatusBadRequest, `unable to decode payload: json: unknown field "nickname"`, "user_update_unknown_field"},
+	}
+
+	for i, tc := range tt {
+		t.Logf("\tTest %d:\tWhen handling %s.", i, tc.name)
+		{
+			tc.req.Expect().
+				Status(tc.status).
+				Header("Content-Type", "application/json").
+				Field("error", tc.error).
+				Golden(tc.golden)
+		}
+	}
+}
+
+func TestUsers(t *testing.T) {
+	log, db := tests.NewUnit(t)
+
+	api := handlers.APIMux(handlers.APIMuxConfig{
+		Shutdown:       make(chan os.Signal, 1),
+		Log:            log,
+		DB:             db,
+		IdempotencyTTL: time.Hour,
+	})
+	wt := tests.NewWebTest(t, api, nil)
+
+	t.Log("Given the need to work with users through the API.")
+
+	t.Log("\tTest 0:\tWhen reading a seeded user.")
+	{
+		wt.Get("/v1/users/"+tests.SeedAdminID).Expect().
+			Status(http.StatusOK).
+			Header("ETag", `"1"`).
+			Field("email", "admin@example.com").
+			Golden("user_get")
+
+		wt.Get("/v1/users/"+tests.SeedAdminID).Header("If-None-Match", `"1"`).Expect().
+			Status(http.StatusNotModified)
+
+		wt.Get("/v1/users/00000000-0000-0000-0000-000000000000").Expect().
+			Status(http.StatusNotFound)
+	}
+
+	t.Log("\tTest 1:\tWhen creating, updating and deleting a user.")
+	{
+		nu := user.NewUser{
+			Name:            "Bill Kennedy",
+			Email:           "bill@ardanlabs.com",
+			Roles:           []string{"ADMIN"},
+			Password:        "gophers",
+			PasswordConfirm: "gophers",
+		}
+
+		var usr user.User
+		wt.Post("/v1/users", nu).Header("Idempotency-Key", "create-bill").Expect().
+			Status(http.StatusCreated).
+			Golden("user_create").
+			JSON(&usr)
+
+		wt.Post("/v1/users", nu).Header("Idempotency-Key", "create-bill").Expect().
+			Status(http.StatusCreated).
+			Header("Idempotent-Replayed", "true").
+			Field("id", usr.ID)
+
+		nu.Name = "Jack Kennedy"
+		wt.Post("/v1/users", nu).Header("Idempotency-Key", "create-bill").Expect().
+			Status(http.StatusUnprocessableEntity)
+
+		wt.Post("/v1/users", user.NewUser{Name: "Jack"}).Expect().
+			Status(http.StatusBadRequest).
+			Field("error", "data validation error").
+			Golden("user_create_invalid")
+
+		name := "Jacob Kennedy"
+		wt.Put("/v1/users/"+usr.ID, user.UpdateUser{Name: &name}).Header("If-Match", `"1"`).Expect().
+			Status(http.StatusOK).
+			Header("ETag", `"2"`).
+			Field("name", name).
+			Golden("user_update")
+
+		wt.Put("/v1/users/"+usr.ID, user.UpdateUser{Name: &name}).Header("If-Match", `"1"`).Expect().
+			Status(http.StatusPreconditionFailed)
+
+		wt.Delete("/v1/users/" + usr.ID).Expect().
+			Status(http.StatusNoContent)
+
+		wt.Get("/v1/users/" + usr.ID).Expect().
+			Status(http.StatusNotFound)
+	}
+}
@@ -6,12 +6,14 @@ import (
 	"crypto/rsa"
 	"crypto/x509"
 	"encoding/pem"
+	"errors"
 	"fmt"
 	"os"
 	"time"
 
 	"github.com/mohammadhsn/ultimate-service/business/data/schema"
 	"github.com/mohammadhsn/ultimate-service/business/sys/database"
+	"github.com/mohammadhsn/ultimate-service/business/web/mid"
 )
 
 var dbCfg = database.Config{
@@ -25,7 +27,19 @@ var dbCfg = database.Config{
 }
 
 func main() {
-	err := migrate()
+	var cmd string
+	if len(os.Args) > 1 {
+		cmd = os.Args[1]
+	}
+
+	var err error
+
+	switch cmd {
+	case "debugtoken":
+		err = debugToken()
+	default:
+		err = migrate()
+	}
 
 	if err != nil {
 		fmt.Println(err)
@@ -127,3 +141,18 @@ func genKey() error {
 
 	return nil
 }
+
+// debugToken generates a token for the debug log header, signed with the key
+// from SALES_LOG_DEBUGKEY and valid for one hour.
+func debugToken() error {
+	key := os.Getenv("SALES_LOG_DEBUGKEY")
+	if key == "" {
+		return errors.New("SALES_LOG_DEBUGKEY is not set")
+	}
+
+	token := mid.NewDebugToken(key, time.Now().Add(time.Hour))
+
+	fmt.Printf("%s: %s\n", mid.DebugHeader, token)
+
+	return nil
+}
@@ -0,0 +1,220 @@
+// This program generates load against a running sales service to measure
+// its latency, errors and throughput at a target request rate.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/mohammadhsn/ultimate-service/business/client"
+)
+
+const usage = `usage: loadgen [flags]
+
+Runs the scenarios against the service at the target rate, picking them by
+weight, and reports latency percentiles, errors and throughput per scenario.
+
+scenarios:
+  read-user     reads one of the seeded users
+  create-user   creates a user with a unique email
+  update-user   reads and updates a user created by the run
+
+The API has no product, sale or token endpoints yet, so the login,
+browse-products and record-sale scenarios are not available.
+
+The service limits the rate of every client, start it with
+SALES_RATELIMIT_RATE=0 to measure it without the limiter. Rejected requests
+are reported as "429 rate limit exceeded" errors.
+
+flags:`
+
+func main() {
+	fs := flag.NewFlagSet("loadgen", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), usage)
+		fs.PrintDefaults()
+	}
+
+	baseURL := fs.String("url", "http://localhost:3000", "address of the sales API")
+	rps := fs.Float64("rps", 50, "target rate of scenario runs per second")
+	duration := fs.Duration("duration", 30*time.Second, "how long to generate load")
+	workers := fs.Int("workers", 100, "maximum number of scenario runs in flight")
+	timeout := fs.Duration("timeout", 5*time.Second, "time allowed for a scenario run")
+	mix := fs.String("scenarios", "read-user=8,create-user=1,update-user=1", "weighted scenarios to run")
+	save := fs.String("save", "", "file to save the report to, as a baseline for later runs")
+	baseline := fs.String("baseline", "", "report file to compare the run against")
+	tolerance := fs.Float64("tolerance", 10, "percentage a metric may get worse than the baseline, percentage points for error rates")
+	cleanup := fs.Bool("cleanup", true, "delete the users created by the run")
+
+	if err := fs.Parse(os.Args[1:]); err != nil {
+		os.Exit(2)
+	}
+
+	if err := run(config{
+		baseURL:   *baseURL,
+		rps:       *rps,
+		duration:  *duration,
+		workers:   *workers,
+		timeout:   *timeout,
+		mix:       *mix,
+		save:      *save,
+		baseline:  *baseline,
+		tolerance: *tolerance,
+		cleanup:   *cleanup,
+	}); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		if errors.Is(err, errUsage) {
+			fs.Usage()
+			os.Exit(2)
+		}
+		os.Exit(1)
+	}
+}
+
+// errUsage reports flags that can not be run.
+var errUsage = errors.New("invalid usage")
+
+// config contains the settings of a run.
+type config struct {
+	baseURL   string
+	rps       float64
+	duration  time.Duration
+	workers   int
+	timeout   time.Duration
+	mix       string
+	save      string
+	baseline  string
+	tolerance float64
+	cleanup   bool
+}
+
+func run(cfg config) error {
+	if cfg.rps <= 0 || cfg.duration <= 0 || cfg.workers <= 0 {
+		return fmt.Errorf("%w: rps, duration and workers must be positive", errUsage)
+	}
+
+	scenarios, err := parseScenarios(cfg.mix)
+	if err != nil {
+		return err
+	}
+
+	var base *report
+	if cfg.baseline != "" {
+		if base, err = loadReport(cfg.baseline); err != nil {
+			return err
+		}
+	}
+
+	// The client does not retry, every failure is part of the results. The
+	// transport keeps a connection per worker.
+	c := client.New(client.Config{
+		BaseURL: cfg.baseURL,
+		HTTPClient: &http.Client{
+			Transport: &http.Transport{
+				Proxy:               http.ProxyFromEnvironment,
+				MaxIdleConns:        cfg.workers,
+				MaxIdleConnsPerHost: cfg.workers,
+				IdleConnTimeout:     90 * time.Second,
+			},
+			Timeout: cfg.timeout,
+		},
+	})
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	ctx, cancel := context.WithTimeout(ctx, cfg.duration)
+	defer cancel()
+
+	st := newState(c)
+	rec := newRecorder()
+
+	fmt.Printf("generating %.0f runs/s for %s against %s\n", cfg.rps, cfg.duration, cfg.baseURL)
+
+	generate(ctx, cfg, scenarios, st, rec)
+
+	rep := rec.report(cfg.rps)
+	rep.print(os.Stdout)
+
+	if cfg.cleanup {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+		if n, err := st.cleanup(ctx); err != nil {
+			fmt.Fprintf(os.Stderr, "cleanup: deleted %d users: %s\n", n, err)
+		}
+	}
+
+	if cfg.save != "" {
+		if err := rep.save(cfg.save); err != nil {
+			return err
+		}
+		fmt.Printf("\nreport saved to %s\n", cfg.save)
+	}
+
+	if base != nil {
+		if regressions := rep.compare(os.Stdout, base, cfg.tolerance); regressions > 0 {
+			return fmt.Errorf("%d metrics regressed by more than %.0f%%", regressions, cfg.tolerance)
+		}
+	}
+
+	return nil
+}
+
+// generate starts scenario runs at the target rate until the context is
+// done, then waits for the runs in flight. The load is open: runs are
+// started on schedule whatever the latency of the service, and runs that
+// can not start because all the workers are busy are counted as dropped.
+func generate(ctx context.Context, cfg config, scenarios []scenario, st *state, rec *recorder) {
+	sem := make(chan struct{}, cfg.workers)
+	pick := newPicker(scenarios, rand.New(rand.NewSource(time.Now().UnixNano())).Intn)
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	interval := time.Duration(float64(time.Second) / cfg.rps)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	rec.start()
+	defer rec.stop()
+
+	for seq := 0; ; seq++ {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		sc := pick()
+
+		select {
+		case sem <- struct{}{}:
+		default:
+			rec.drop(sc.name)
+			continue
+		}
+
+		wg.Add(1)
+		go func(seq int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			// Runs in flight when the load stops are allowed to finish.
+			ctx, cancel := context.WithTimeout(context.Background(), cfg.timeout)
+			defer cancel()
+
+			start := time.Now()
+			err := sc.run(ctx, st, seq)
+			rec.record(sc.name, time.Since(start), err)
+		}(seq)
+	}
+}
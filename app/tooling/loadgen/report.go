@@ -0,0 +1,352 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"os"
+	"sort"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/mohammadhsn/ultimate-service/business/client"
+)
+
+// total is the name of the report line adding up all the scenarios.
+const total = "total"
+
+// results holds what was recorded for a scenario.
+type results struct {
+	latencies []time.Duration
+	errors    map[string]int
+	dropped   int
+}
+
+// recorder collects the results of the scenario runs.
+type recorder struct {
+	mu        sync.Mutex
+	scenarios map[string]*results
+	began     time.Time
+	ended     time.Time
+}
+
+// newRecorder constructs an empty recorder.
+func newRecorder() *recorder {
+	return &recorder{
+		scenarios: make(map[string]*results),
+	}
+}
+
+// start marks the start of the load.
+func (r *recorder) start() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.began = time.Now()
+}
+
+// stop marks the end of the load, once the runs in flight are done.
+func (r *recorder) stop() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.ended = time.Now()
+}
+
+// results returns the results of the scenario. The caller must hold the
+// lock.
+func (r *recorder) results(name string) *results {
+	res, ok := r.scenarios[name]
+	if !ok {
+		res = &results{errors: make(map[string]int)}
+		r.scenarios[name] = res
+	}
+	return res
+}
+
+// record adds the result of a scenario run. Only the latency of successful
+// runs is kept, failures are counted by kind.
+func (r *recorder) record(name string, d time.Duration, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	res := r.results(name)
+	if err != nil {
+		res.errors[classify(err)]++
+		return
+	}
+	res.latencies = append(res.latencies, d)
+}
+
+// drop counts a scenario run that could not start on schedule.
+func (r *recorder) drop(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.results(name).dropped++
+}
+
+// classify returns the kind of failure of a scenario run. Error responses
+// are told apart by their status code and the error of the
+// validate.ErrorResponse the service sent.
+func classify(err error) string {
+	var ce *client.Error
+	var ne net.Error
+	switch {
+	case errors.As(err, &ce):
+		return fmt.Sprintf("%d %s", ce.StatusCode, ce.ErrorResponse.Error)
+	case errors.Is(err, errNoUser):
+		return "skipped: " + err.Error()
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &ne) && ne.Timeout():
+		return "timeout"
+	default:
+		return "network error"
+	}
+}
+
+// report returns the summary of the results.
+func (r *recorder) report(rps float64) *report {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	rep := report{
+		Date:      r.began,
+		TargetRPS: rps,
+		Duration:  r.ended.Sub(r.began).Seconds(),
+	}
+
+	all := results{errors: make(map[string]int)}
+	for name, res := range r.scenarios {
+		rep.Scenarios = append(rep.Scenarios, summarize(name, res, rep.Duration))
+
+		all.latencies = append(all.latencies, res.latencies...)
+		all.dropped += res.dropped
+		for kind, n := range res.errors {
+			all.errors[kind] += n
+		}
+	}
+
+	sort.Slice(rep.Scenarios, func(i, j int) bool {
+		return rep.Scenarios[i].Name < rep.Scenarios[j].Name
+	})
+	rep.Scenarios = append(rep.Scenarios, summarize(total, &all, rep.Duration))
+
+	return &rep
+}
+
+// report is the summary of a load test, saved as JSON to be used as the
+// baseline of later runs.
+type report struct {
+	Date      time.Time        `json:"date"`
+	TargetRPS float64          `json:"targetRps"`
+	Duration  float64          `json:"durationSeconds"`
+	Scenarios []scenarioReport `json:"scenarios"`
+}
+
+// scenarioReport is the summary of the runs of a scenario. Latencies are
+// in milliseconds.
+type scenarioReport struct {
+	Name       string         `json:"name"`
+	Runs       int            `json:"runs"`
+	Errors     int            `json:"errors"`
+	Dropped    int            `json:"dropped"`
+	Throughput float64        `json:"throughput"`
+	ErrorRate  float64        `json:"errorRate"`
+	P50        float64        `json:"p50"`
+	P90        float64        `json:"p90"`
+	P95        float64        `json:"p95"`
+	P99        float64        `json:"p99"`
+	Max        float64        `json:"max"`
+	ErrorKinds map[string]int `json:"errorKinds,omitempty"`
+}
+
+// summarize computes the summary of the results of a scenario.
+func summarize(name string, res *results, seconds float64) scenarioReport {
+	sr := scenarioReport{
+		Name:       name,
+		Dropped:    res.dropped,
+		ErrorKinds: res.errors,
+	}
+
+	for _, n := range res.errors {
+		sr.Errors += n
+	}
+	sr.Runs = len(res.latencies) + sr.Errors
+
+	if sr.Runs > 0 {
+		sr.ErrorRate = float64(sr.Errors) / float64(sr.Runs) * 100
+	}
+	if seconds > 0 {
+		sr.Throughput = float64(len(res.latencies)) / seconds
+	}
+
+	lat := make([]time.Duration, len(res.latencies))
+	copy(lat, res.latencies)
+	sort.Slice(lat, func(i, j int) bool { return lat[i] < lat[j] })
+
+	sr.P50 = percentile(lat, 50)
+	sr.P90 = percentile(lat, 90)
+	sr.P95 = percentile(lat, 95)
+	sr.P99 = percentile(lat, 99)
+	sr.Max = percentile(lat, 100)
+
+	return sr
+}
+
+// percentile returns the nearest rank percentile of the sorted latencies,
+// in milliseconds.
+func percentile(sorted []time.Duration, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+
+	return float64(sorted[rank-1]) / float64(time.Millisecond)
+}
+
+// print writes the report as a table, followed by the kinds of errors.
+func (rep *report) print(w io.Writer) {
+	fmt.Fprintf(w, "\n%.1fs at a target of %.0f runs/s\n\n", rep.Duration, rep.TargetRPS)
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "SCENARIO\tRUNS\tOK/S\tERRORS\tDROPPED\tP50\tP90\tP95\tP99\tMAX")
+	for _, sr := range rep.Scenarios {
+		fmt.Fprintf(tw, "%s\t%d\t%.1f\t%d (%.1f%%)\t%d\t%.1fms\t%.1fms\t%.1fms\t%.1fms\t%.1fms\n",
+			sr.Name, sr.Runs, sr.Throughput, sr.Errors, sr.ErrorRate, sr.Dropped, sr.P50, sr.P90, sr.P95, sr.P99, sr.Max)
+	}
+	tw.Flush()
+
+	for _, sr := range rep.Scenarios {
+		if sr.Name == total || len(sr.ErrorKinds) == 0 {
+			continue
+		}
+
+		fmt.Fprintf(w, "\n%s errors:\n", sr.Name)
+
+		kinds := make([]string, 0, len(sr.ErrorKinds))
+		for kind := range sr.ErrorKinds {
+			kinds = append(kinds, kind)
+		}
+		sort.Slice(kinds, func(i, j int) bool {
+			return sr.ErrorKinds[kinds[i]] > sr.ErrorKinds[kinds[j]]
+		})
+
+		for _, kind := range kinds {
+			fmt.Fprintf(w, "  %6d  %s\n", sr.ErrorKinds[kind], kind)
+		}
+	}
+}
+
+// save writes the report to the file as JSON.
+func (rep *report) save(path string) error {
+	b, err := json.MarshalIndent(rep, "", "    ")
+	if err != nil {
+		return fmt.Errorf("encoding report: %w", err)
+	}
+
+	if err := os.WriteFile(path, b, 0644); err != nil {
+		return fmt.Errorf("saving report: %w", err)
+	}
+
+	return nil
+}
+
+// loadReport reads a report saved by an earlier run.
+func loadReport(path string) (*report, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading baseline: %w", err)
+	}
+
+	var rep report
+	if err := json.Unmarshal(b, &rep); err != nil {
+		return nil, fmt.Errorf("decoding baseline %s: %w", path, err)
+	}
+
+	return &rep, nil
+}
+
+// compare writes how the scenarios of the baseline changed and returns how
+// many metrics got worse by more than the tolerance. Latencies and
+// throughput are compared in percent, error rates in percentage points. A
+// latency going up from a baseline of zero is an infinite change, and a
+// scenario of the baseline missing from the run counts as one regression.
+func (rep *report) compare(w io.Writer, base *report, tolerance float64) int {
+	baseline := make(map[string]scenarioReport)
+	for _, sr := range base.Scenarios {
+		baseline[sr.Name] = sr
+	}
+
+	current := make(map[string]bool)
+	for _, sr := range rep.Scenarios {
+		current[sr.Name] = true
+	}
+
+	fmt.Fprintf(w, "\ncompared to the baseline of %s:\n\n", base.Date.Format(time.RFC3339))
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "SCENARIO\tMETRIC\tBASELINE\tNOW\tCHANGE\t")
+
+	var regressions int
+	for _, sr := range rep.Scenarios {
+		b, ok := baseline[sr.Name]
+		if !ok {
+			continue
+		}
+
+		metrics := []struct {
+			name       string
+			base, now  float64
+			higherBad  bool
+			percentage bool
+		}{
+			{"ok/s", b.Throughput, sr.Throughput, false, false},
+			{"errors %", b.ErrorRate, sr.ErrorRate, true, true},
+			{"p50 ms", b.P50, sr.P50, true, false},
+			{"p95 ms", b.P95, sr.P95, true, false},
+			{"p99 ms", b.P99, sr.P99, true, false},
+		}
+
+		for _, m := range metrics {
+			var change float64
+			var unit string
+			switch {
+			case m.percentage:
+				change, unit = m.now-m.base, "pp"
+			case m.base != 0:
+				change, unit = (m.now-m.base)/m.base*100, "%"
+			case m.now != 0:
+				change, unit = math.Inf(1), "%"
+			}
+
+			worse := change
+			if !m.higherBad {
+				worse = -change
+			}
+
+			mark := ""
+			if worse > tolerance {
+				mark = "REGRESSED"
+				regressions++
+			}
+
+			fmt.Fprintf(tw, "%s\t%s\t%.1f\t%.1f\t%+.1f%s\t%s\n", sr.Name, m.name, m.base, m.now, change, unit, mark)
+		}
+	}
+
+	for _, b := range base.Scenarios {
+		if !current[b.Name] {
+			fmt.Fprintf(tw, "%s\truns\t%d\t-\tmissing\tREGRESSED\n", b.Name, b.Runs)
+			regressions++
+		}
+	}
+	tw.Flush()
+
+	return regressions
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"io"
+	"math/rand"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	success = "\u2713"
+	failed  = "\u2717"
+)
+
+func TestPercentile(t *testing.T) {
+	t.Log("Given the need to report nearest rank percentiles of latencies.")
+
+	ms := func(ns ...int) []time.Duration {
+		d := make([]time.Duration, len(ns))
+		for i, n := range ns {
+			d[i] = time.Duration(n) * time.Millisecond
+		}
+		return d
+	}
+
+	tt := []struct {
+		name   string
+		sorted []time.Duration
+		p      float64
+		exp    float64
+	}{
+		{"no latencies", nil, 50, 0},
+		{"a single latency", ms(7), 99, 7},
+		{"the median of four", ms(1, 2, 3, 4), 50, 2},
+		{"the p90 of ten", ms(1, 2, 3, 4, 5, 6, 7, 8, 9, 10), 90, 9},
+		{"the p99 of ten", ms(1, 2, 3, 4, 5, 6, 7, 8, 9, 10), 99, 10},
+		{"the max", ms(1, 2, 3), 100, 3},
+		{"the p0", ms(1, 2, 3), 0, 1},
+		{"a fraction of a millisecond", []time.Duration{1500 * time.Microsecond}, 50, 1.5},
+	}
+
+	for i, tc := range tt {
+		t.Logf("\tTest %d:\tWhen computing %s.", i, tc.name)
+		{
+			if got := percentile(tc.sorted, tc.p); got != tc.exp {
+				t.Fatalf("\t%s\tTest %d:\tShould get %.1fms : got %.1fms.", failed, i, tc.exp, got)
+			}
+			t.Logf("\t%s\tTest %d:\tShould get %.1fms.", success, i, tc.exp)
+		}
+	}
+}
+
+func TestPicker(t *testing.T) {
+	t.Log("Given the need to pick scenarios in proportion to their weights.")
+
+	scs := []scenario{
+		{name: "read-user", weight: 3},
+		{name: "create-user", weight: 1},
+		{name: "update-user", weight: 2},
+	}
+
+	tt := []struct {
+		n   int
+		exp string
+	}{
+		{0, "read-user"},
+		{2, "read-user"},
+		{3, "create-user"},
+		{4, "update-user"},
+		{5, "update-user"},
+	}
+
+	for i, tc := range tt {
+		t.Logf("\tTest %d:\tWhen the random number is %d.", i, tc.n)
+		{
+			var total int
+			pick := newPicker(scs, func(n int) int {
+				total = n
+				return tc.n
+			})
+
+			if got := pick().name; got != tc.exp {
+				t.Fatalf("\t%s\tTest %d:\tShould pick %s : got %s.", failed, i, tc.exp, got)
+			}
+			t.Logf("\t%s\tTest %d:\tShould pick %s.", success, i, tc.exp)
+
+			if total != 6 {
+				t.Fatalf("\t%s\tTest %d:\tShould draw below the total weight of 6 : got %d.", failed, i, total)
+			}
+			t.Logf("\t%s\tTest %d:\tShould draw below the total weight of 6.", success, i)
+		}
+	}
+
+	i := len(tt)
+	t.Logf("\tTest %d:\tWhen picking many times at random.", i)
+	{
+		const runs = 60000
+
+		pick := newPicker(scs, rand.New(rand.NewSource(1)).Intn)
+		counts := make(map[string]int)
+		for n := 0; n < runs; n++ {
+			counts[pick().name]++
+		}
+
+		for _, sc := range scs {
+			exp := runs * sc.weight / 6
+			if d := counts[sc.name] - exp; d < -exp/20 || d > exp/20 {
+				t.Fatalf("\t%s\tTest %d:\tShould pick %s about %d times : got %d.", failed, i, sc.name, exp, counts[sc.name])
+			}
+			t.Logf("\t%s\tTest %d:\tShould pick %s about %d times.", success, i, sc.name, exp)
+		}
+	}
+}
+
+func TestCompare(t *testing.T) {
+	t.Log("Given the need to fail a run that regressed since the baseline.")
+
+	base := scenarioReport{Name: "read-user", Runs: 100, Throughput: 100, ErrorRate: 1, P50: 10, P95: 20, P99: 40}
+
+	with := func(change func(sr *scenarioReport)) scenarioReport {
+		sr := base
+		change(&sr)
+		return sr
+	}
+
+	tt := []struct {
+		name        string
+		base        []scenarioReport
+		now         []scenarioReport
+		regressions int
+		output      string
+	}{
+		{"an unchanged run", []scenarioReport{base}, []scenarioReport{base}, 0, ""},
+		{"a latency within the tolerance", []scenarioReport{base}, []scenarioReport{with(func(sr *scenarioReport) { sr.P99 = 43 })}, 0, ""},
+		{"a slower p95 and p99", []scenarioReport{base}, []scenarioReport{with(func(sr *scenarioReport) { sr.P95, sr.P99 = 30, 60 })}, 2, "p95 ms"},
+		{"a faster run", []scenarioReport{base}, []scenarioReport{with(func(sr *scenarioReport) { sr.Throughput, sr.P50 = 200, 1 })}, 0, ""},
+		{"a lower throughput", []scenarioReport{base}, []scenarioReport{with(func(sr *scenarioReport) { sr.Throughput = 50 })}, 1, "ok/s"},
+		{"more errors", []scenarioReport{base}, []scenarioReport{with(func(sr *scenarioReport) { sr.ErrorRate = 12 })}, 1, "errors %"},
+		{"a latency up from zero", []scenarioReport{with(func(sr *scenarioReport) { sr.P50 = 0 })}, []scenarioReport{base}, 1, "+Inf%"},
+		{"a latency staying at zero", []scenarioReport{with(func(sr *scenarioReport) { sr.P50 = 0 })}, []scenarioReport{with(func(sr *scenarioReport) { sr.P50 = 0 })}, 0, ""},
+		{"a scenario missing from the run", []scenarioReport{base, {Name: "create-user", Runs: 10}}, []scenarioReport{base}, 1, "missing"},
+		{"a scenario missing from the baseline", []scenarioReport{base}, []scenarioReport{base, {Name: "create-user", Runs: 10}}, 0, ""},
+	}
+
+	for i, tc := range tt {
+		t.Logf("\tTest %d:\tWhen comparing %s.", i, tc.name)
+		{
+			var out strings.Builder
+			rep := report{Scenarios: tc.now}
+			got := rep.compare(&out, &report{Scenarios: tc.base}, 10)
+
+			if got != tc.regressions {
+				t.Fatalf("\t%s\tTest %d:\tShould find %d regressions : got %d\n%s", failed, i, tc.regressions, got, out.String())
+			}
+			t.Logf("\t%s\tTest %d:\tShould find %d regressions.", success, i, tc.regressions)
+
+			if tc.output != "" {
+				var line string
+				for _, l := range strings.Split(out.String(), "\n") {
+					if strings.Contains(l, "REGRESSED") {
+						line = l
+						break
+					}
+				}
+				if !strings.Contains(line, tc.output) {
+					t.Fatalf("\t%s\tTest %d:\tShould mark %q as regressed : got\n%s", failed, i, tc.output, out.String())
+				}
+				t.Logf("\t%s\tTest %d:\tShould mark %q as regressed.", success, i, tc.output)
+			}
+		}
+	}
+
+	i := len(tt)
+	t.Logf("\tTest %d:\tWhen comparing against an empty baseline.", i)
+	{
+		rep := report{Scenarios: []scenarioReport{base}}
+		if got := rep.compare(io.Discard, &report{}, 10); got != 0 {
+			t.Fatalf("\t%s\tTest %d:\tShould find no regressions : got %d.", failed, i, got)
+		}
+		t.Logf("\t%s\tTest %d:\tShould find no regressions.", success, i)
+	}
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mohammadhsn/ultimate-service/business/client"
+	"github.com/mohammadhsn/ultimate-service/business/data/store/user"
+)
+
+// seedUserIDs are the users added by the seed data of the schema package,
+// which every database of the service has.
+var seedUserIDs = []string{
+	"5cf37266-3473-4006-984f-9325122678b7",
+	"45b5fbd3-755f-4379-8f07-a58d4a30fa2f",
+}
+
+// scenario is a user interaction with the service, made of one or more
+// requests.
+type scenario struct {
+	name   string
+	weight int
+	run    func(ctx context.Context, st *state, seq int) error
+}
+
+// scenarios are the interactions that can be run.
+var scenarios = map[string]func(ctx context.Context, st *state, seq int) error{
+	"read-user":   readUser,
+	"create-user": createUser,
+	"update-user": updateUser,
+}
+
+// unsupported are the interactions the API has no endpoints for yet.
+var unsupported = map[string]bool{
+	"login":           true,
+	"browse-products": true,
+	"record-sale":     true,
+}
+
+// parseScenarios parses a list of scenarios like read-user=8,create-user=1.
+// A scenario without a weight has a weight of 1.
+func parseScenarios(mix string) ([]scenario, error) {
+	var scs []scenario
+	for _, part := range strings.Split(mix, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		name, w, ok := strings.Cut(part, "=")
+		weight := 1
+		if ok {
+			n, err := strconv.Atoi(w)
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("%w: invalid weight of scenario %q", errUsage, name)
+			}
+			weight = n
+		}
+
+		if unsupported[name] {
+			return nil, fmt.Errorf("%s: not supported by the API yet", name)
+		}
+		run, ok := scenarios[name]
+		if !ok {
+			return nil, fmt.Errorf("%w: unknown scenario %q", errUsage, name)
+		}
+
+		if weight > 0 {
+			scs = append(scs, scenario{name: name, weight: weight, run: run})
+		}
+	}
+
+	if len(scs) == 0 {
+		return nil, fmt.Errorf("%w: no scenario to run", errUsage)
+	}
+
+	return scs, nil
+}
+
+// newPicker returns a function picking a scenario at random, in proportion
+// to the weights. intn returns a random number in [0, n), like rand.Intn.
+func newPicker(scs []scenario, intn func(n int) int) func() scenario {
+	total := 0
+	for _, sc := range scs {
+		total += sc.weight
+	}
+
+	return func() scenario {
+		n := intn(total)
+		for _, sc := range scs {
+			if n < sc.weight {
+				return sc
+			}
+			n -= sc.weight
+		}
+		return scs[len(scs)-1]
+	}
+}
+
+// state is shared by the scenario runs of a load test.
+type state struct {
+	client *client.Client
+	runID  string
+
+	mu      sync.Mutex
+	created []string
+}
+
+// newState constructs the state of a new run.
+func newState(c *client.Client) *state {
+	return &state{
+		client: c,
+		runID:  strconv.FormatInt(time.Now().Unix(), 36),
+	}
+}
+
+// add records a user created by the run.
+func (st *state) add(id string) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.created = append(st.created, id)
+}
+
+// pick returns a user created by the run, if any.
+func (st *state) pick(seq int) (string, bool) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if len(st.created) == 0 {
+		return "", false
+	}
+	return st.created[seq%len(st.created)], true
+}
+
+// cleanup deletes the users created by the run and returns how many were
+// deleted.
+func (st *state) cleanup(ctx context.Context) (int, error) {
+	st.mu.Lock()
+	ids := st.created
+	st.created = nil
+	st.mu.Unlock()
+
+	for i, id := range ids {
+		err := st.client.DeleteUser(ctx, id)
+		if err != nil && client.StatusCode(err) != http.StatusNotFound {
+			return i, err
+		}
+	}
+
+	return len(ids), nil
+}
+
+// readUser reads one of the seeded users.
+func readUser(ctx context.Context, st *state, seq int) error {
+	_, err := st.client.QueryUserByID(ctx, seedUserIDs[seq%len(seedUserIDs)])
+	return err
+}
+
+// createUser creates a user with an email unique to the run.
+func createUser(ctx context.Context, st *state, seq int) error {
+	nu := user.NewUser{
+		Name:            fmt.Sprintf("Load Gopher %d", seq),
+		Email:           fmt.Sprintf("loadgen-%s-%d@example.com", st.runID, seq),
+		Roles:           []string{"USER"},
+		Password:        "gophers",
+		PasswordConfirm: "gophers",
+	}
+
+	usr, err := st.client.CreateUser(ctx, nu)
+	if err != nil {
+		return err
+	}
+
+	st.add(usr.ID)
+	return nil
+}
+
+// errNoUser is returned by scenarios needing a user the run has not created
+// yet.
+var errNoUser = errors.New("no user created by the run yet")
+
+// updateUser reads a user created by the run and renames it, using the
+// version it read.
+func updateUser(ctx context.Context, st *state, seq int) error {
+	id, ok := st.pick(seq)
+	if !ok {
+		return errNoUser
+	}
+
+	usr, err := st.client.QueryUserByID(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf("Load Gopher %d", seq)
+	_, err = st.client.UpdateUser(ctx, id, user.UpdateUser{Name: &name}, usr.Version)
+	return err
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// tsLayout is the layout of the ts field written by foundation/logger.
+const tsLayout = "2006-01-02T15:04:05.000Z0700"
+
+// levels orders the zap levels by severity.
+var levels = map[string]int{
+	"debug":  0,
+	"info":   1,
+	"warn":   2,
+	"error":  3,
+	"dpanic": 4,
+	"panic":  5,
+	"fatal":  6,
+}
+
+// filter decides which log lines are shown.
+type filter struct {
+	service  string
+	minLevel int
+	traceID  string
+	match    *regexp.Regexp
+	since    time.Time
+	until    time.Time
+}
+
+// newFilter constructs a filter from the command line flags. Relative times
+// are resolved against now.
+func newFilter(now time.Time) (filter, error) {
+	f := filter{
+		service: service,
+		traceID: traceID,
+	}
+
+	if level != "" {
+		lvl, ok := levels[strings.ToLower(level)]
+		if !ok {
+			return filter{}, fmt.Errorf("unknown -level %q", level)
+		}
+		f.minLevel = lvl
+	}
+
+	var err error
+	if match != "" {
+		if f.match, err = regexp.Compile(match); err != nil {
+			return filter{}, fmt.Errorf("parsing -match: %w", err)
+		}
+	}
+
+	if f.since, err = parseTime(since, now); err != nil {
+		return filter{}, fmt.Errorf("parsing -since: %w", err)
+	}
+	if f.until, err = parseTime(until, now); err != nil {
+		return filter{}, fmt.Errorf("parsing -until: %w", err)
+	}
+
+	return f, nil
+}
+
+// empty reports whether the filter lets every line through.
+func (f filter) empty() bool {
+	return f.service == "" && f.minLevel == 0 && f.traceID == "" &&
+		f.match == nil && f.since.IsZero() && f.until.IsZero()
+}
+
+// keep reports whether the log line passes the filter.
+func (f filter) keep(m map[string]interface{}) bool {
+	if f.service != "" && m["service"] != f.service {
+		return false
+	}
+
+	if f.minLevel > 0 {
+		lvl, _ := m["level"].(string)
+		if levels[lvl] < f.minLevel {
+			return false
+		}
+	}
+
+	if f.traceID != "" && m["traceID"] != f.traceID {
+		return false
+	}
+
+	if f.match != nil {
+		msg, _ := m["msg"].(string)
+		if !f.match.MatchString(msg) {
+			return false
+		}
+	}
+
+	if !f.since.IsZero() || !f.until.IsZero() {
+		s, _ := m["ts"].(string)
+		ts, err := time.Parse(tsLayout, s)
+		if err != nil {
+			return false
+		}
+		if !f.since.IsZero() && ts.Before(f.since) {
+			return false
+		}
+		if !f.until.IsZero() && ts.After(f.until) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// parseTime accepts an RFC3339 time or a duration that is subtracted from
+// now. An empty value returns the zero time.
+func parseTime(value string, now time.Time) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	if d, err := time.ParseDuration(value); err == nil {
+		return now.Add(-d), nil
+	}
+
+	return time.Parse(time.RFC3339, value)
+}
+
+// =============================================================================
+
+// Limits on what the trace follower keeps in memory while waiting for errors
+// and once traces matched.
+const (
+	maxPendingTraces = 1000
+	maxPendingLines  = 100
+	maxMatchedTraces = 1000
+)
+
+// traceFollower holds back lines until their trace ID has an error. From
+// then on the lines of that trace are shown, including the ones held back.
+// Only the most recent traces are remembered, pending or matched.
+type traceFollower struct {
+	pending      map[string][]entry
+	order        []string
+	matched      map[string]bool
+	matchedOrder []string
+}
+
+// newTraceFollower constructs an empty trace follower.
+func newTraceFollower() *traceFollower {
+	return &traceFollower{
+		matched: make(map[string]bool),
+		pending: make(map[string][]entry),
+	}
+}
+
+// add records the entry and returns the entries that should be shown now.
+func (tf *traceFollower) add(e entry) []entry {
+	id := e.str("traceID")
+	if id == "" || id == zeroTraceID {
+		return nil
+	}
+
+	if tf.matched[id] {
+		return []entry{e}
+	}
+
+	if levels[e.str("level")] >= levels["error"] {
+		tf.match(id)
+		out := append(tf.pending[id], e)
+		tf.forget(id)
+		return out
+	}
+
+	lines, exists := tf.pending[id]
+	if !exists {
+		tf.order = append(tf.order, id)
+		if len(tf.order) > maxPendingTraces {
+			delete(tf.pending, tf.order[0])
+			tf.order = tf.order[1:]
+		}
+	}
+	if len(lines) < maxPendingLines {
+		tf.pending[id] = append(lines, e)
+	}
+
+	return nil
+}
+
+// match remembers the trace as matched, forgetting the oldest matched trace
+// once there are too many.
+func (tf *traceFollower) match(id string) {
+	tf.matched[id] = true
+	tf.matchedOrder = append(tf.matchedOrder, id)
+	if len(tf.matchedOrder) > maxMatchedTraces {
+		delete(tf.matched, tf.matchedOrder[0])
+		tf.matchedOrder = tf.matchedOrder[1:]
+	}
+}
+
+// forget drops the pending lines of the trace.
+func (tf *traceFollower) forget(id string) {
+	if _, exists := tf.pending[id]; !exists {
+		return
+	}
+	delete(tf.pending, id)
+
+	for i, pid := range tf.order {
+		if pid == id {
+			tf.order = append(tf.order[:i], tf.order[i+1:]...)
+			break
+		}
+	}
+}
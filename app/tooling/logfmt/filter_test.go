@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"testing"
+	"time"
+)
+
+const (
+	success = "\u2713"
+	failed  = "\u2717"
+)
+
+// line constructs an entry with the fields.
+func line(kv ...interface{}) entry {
+	e := entry{fields: make(map[string]interface{})}
+	for i := 0; i+1 < len(kv); i += 2 {
+		e.fields[kv[i].(string)] = kv[i+1]
+	}
+	return e
+}
+
+func TestFilter(t *testing.T) {
+	now := time.Date(2022, 10, 1, 12, 0, 0, 0, time.UTC)
+	ts := now.Add(-time.Minute).Format(tsLayout)
+
+	t.Log("Given the need to filter log lines.")
+
+	tt := []struct {
+		name string
+		f    filter
+		e    entry
+		keep bool
+	}{
+		{"empty filter", filter{}, line("msg", "x"), true},
+		{"other service", filter{service: "SALES"}, line("service", "ADMIN"), false},
+		{"same service", filter{service: "SALES"}, line("service", "SALES"), true},
+		{"lower level", filter{minLevel: levels["warn"]}, line("level", "info"), false},
+		{"higher level", filter{minLevel: levels["warn"]}, line("level", "error"), true},
+		{"other trace", filter{traceID: "a"}, line("traceID", "b"), false},
+		{"message match", filter{match: regexp.MustCompile("^request")}, line("msg", "request started"), true},
+		{"message mismatch", filter{match: regexp.MustCompile("^request")}, line("msg", "startup"), false},
+		{"after since", filter{since: now.Add(-time.Hour)}, line("ts", ts), true},
+		{"before since", filter{since: now}, line("ts", ts), false},
+		{"after until", filter{until: now.Add(-time.Hour)}, line("ts", ts), false},
+		{"bad time", filter{since: now}, line("ts", "yesterday"), false},
+	}
+
+	for i, tc := range tt {
+		t.Logf("\tTest %d:\tWhen filtering with %s.", i, tc.name)
+		{
+			if got := tc.f.keep(tc.e.fields); got != tc.keep {
+				t.Fatalf("\t%s\tTest %d:\tShould keep the line %t: got %t.", failed, i, tc.keep, got)
+			}
+			t.Logf("\t%s\tTest %d:\tShould keep the line %t.", success, i, tc.keep)
+		}
+	}
+}
+
+func TestParseTime(t *testing.T) {
+	now := time.Date(2022, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	t.Log("Given the need to parse absolute and relative times.")
+	{
+		t.Log("\tTest 0:\tWhen parsing a duration and an RFC3339 time.")
+		{
+			got, err := parseTime("15m", now)
+			if err != nil || !got.Equal(now.Add(-15*time.Minute)) {
+				t.Fatalf("\t%s\tTest 0:\tShould subtract the duration from now: got %v, %v.", failed, got, err)
+			}
+			t.Logf("\t%s\tTest 0:\tShould subtract the duration from now.", success)
+
+			got, err = parseTime("2022-10-01T10:00:00Z", now)
+			if err != nil || !got.Equal(now.Add(-2*time.Hour)) {
+				t.Fatalf("\t%s\tTest 0:\tShould parse the time: got %v, %v.", failed, got, err)
+			}
+			t.Logf("\t%s\tTest 0:\tShould parse the time.", success)
+
+			if _, err := parseTime("soon", now); err == nil {
+				t.Fatalf("\t%s\tTest 0:\tShould reject invalid values.", failed)
+			}
+			t.Logf("\t%s\tTest 0:\tShould reject invalid values.", success)
+		}
+	}
+}
+
+func TestTraceFollower(t *testing.T) {
+	t.Log("Given the need to follow the traces with errors.")
+	{
+		t.Log("\tTest 0:\tWhen a trace has an error.")
+		{
+			tf := newTraceFollower()
+
+			if out := tf.add(line("traceID", "a", "level", "info", "msg", "started")); len(out) != 0 {
+				t.Fatalf("\t%s\tTest 0:\tShould hold back lines without errors: got %d.", failed, len(out))
+			}
+			t.Logf("\t%s\tTest 0:\tShould hold back lines without errors.", success)
+
+			out := tf.add(line("traceID", "a", "level", "error", "msg", "failed"))
+			if len(out) != 2 || out[0].str("msg") != "started" {
+				t.Fatalf("\t%s\tTest 0:\tShould show the held back lines with the error: got %v.", failed, out)
+			}
+			t.Logf("\t%s\tTest 0:\tShould show the held back lines with the error.", success)
+
+			if out := tf.add(line("traceID", "a", "level", "info", "msg", "completed")); len(out) != 1 {
+				t.Fatalf("\t%s\tTest 0:\tShould show later lines of the trace: got %d.", failed, len(out))
+			}
+			t.Logf("\t%s\tTest 0:\tShould show later lines of the trace.", success)
+
+			if len(tf.pending) != 0 || len(tf.order) != 0 {
+				t.Fatalf("\t%s\tTest 0:\tShould forget the pending lines of the trace: got %d, %d.", failed, len(tf.pending), len(tf.order))
+			}
+			t.Logf("\t%s\tTest 0:\tShould forget the pending lines of the trace.", success)
+		}
+
+		t.Log("\tTest 1:\tWhen following a long stream.")
+		{
+			tf := newTraceFollower()
+			for i := 0; i < 3*maxMatchedTraces; i++ {
+				id := fmt.Sprintf("trace-%d", i)
+				tf.add(line("traceID", id, "level", "info"))
+				if i%2 == 0 {
+					tf.add(line("traceID", id, "level", "error"))
+				}
+			}
+
+			if len(tf.matched) > maxMatchedTraces || len(tf.matchedOrder) > maxMatchedTraces {
+				t.Fatalf("\t%s\tTest 1:\tShould cap the matched traces: got %d.", failed, len(tf.matched))
+			}
+			t.Logf("\t%s\tTest 1:\tShould cap the matched traces.", success)
+
+			if len(tf.pending) > maxPendingTraces || len(tf.order) != len(tf.pending) {
+				t.Fatalf("\t%s\tTest 1:\tShould cap the pending traces: got %d, %d.", failed, len(tf.pending), len(tf.order))
+			}
+			t.Logf("\t%s\tTest 1:\tShould cap the pending traces.", success)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// zeroTraceID is shown for lines that are not part of a request.
+const zeroTraceID = "00000000000000000000000000000000"
+
+// ANSI escape sequences used to colorize the output.
+const (
+	colorReset   = "\033[0m"
+	colorGray    = "\033[90m"
+	colorCyan    = "\033[36m"
+	colorYellow  = "\033[33m"
+	colorRed     = "\033[31m"
+	colorMagenta = "\033[1;35m"
+)
+
+// levelColors maps the zap levels to their color.
+var levelColors = map[string]string{
+	"debug":  colorGray,
+	"info":   colorCyan,
+	"warn":   colorYellow,
+	"error":  colorRed,
+	"dpanic": colorMagenta,
+	"panic":  colorMagenta,
+	"fatal":  colorMagenta,
+}
+
+// fixedKeys are the fields printed in a fixed order ahead of all others.
+var fixedKeys = []string{"service", "ts", "level", "traceID", "caller", "msg"}
+
+// formatter converts a log entry into an output line.
+type formatter struct {
+	mode  string
+	color bool
+}
+
+// newFormatter constructs a formatter for the output mode.
+func newFormatter(mode string, color bool) (formatter, error) {
+	switch mode {
+	case "pretty", "logfmt", "json":
+	default:
+		return formatter{}, fmt.Errorf("unknown -format %q", mode)
+	}
+
+	return formatter{mode: mode, color: color}, nil
+}
+
+// format returns the output line for the entry.
+func (f formatter) format(e entry) string {
+	switch f.mode {
+	case "logfmt":
+		return f.logfmt(e)
+	case "json":
+		return e.raw
+	default:
+		return f.pretty(e)
+	}
+}
+
+// pretty returns the fixed fields joined by colons followed by the rest of
+// the fields as key[value] pairs.
+func (f formatter) pretty(e entry) string {
+	traceID := e.str("traceID")
+	if traceID == "" {
+		traceID = zeroTraceID
+	}
+
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("%s: %s: %s: %s: %s: %s",
+		e.str("service"),
+		e.str("ts"),
+		f.paint(e.str("level"), e.str("level")),
+		traceID,
+		e.str("caller"),
+		e.str("msg"),
+	))
+
+	for _, k := range otherKeys(e) {
+		b.WriteString(fmt.Sprintf(": %s[%v]", k, e.fields[k]))
+	}
+
+	return b.String()
+}
+
+// logfmt returns the fields as space separated key=value pairs.
+func (f formatter) logfmt(e entry) string {
+	var pairs []string
+	for _, k := range fixedKeys {
+		if _, exists := e.fields[k]; !exists {
+			continue
+		}
+		v := quote(e.str(k))
+		if k == "level" {
+			v = f.paint(e.str(k), v)
+		}
+		pairs = append(pairs, k+"="+v)
+	}
+
+	for _, k := range otherKeys(e) {
+		pairs = append(pairs, k+"="+quote(fmt.Sprintf("%v", e.fields[k])))
+	}
+
+	return strings.Join(pairs, " ")
+}
+
+// paint wraps the text in the color of the level when colors are enabled.
+func (f formatter) paint(level string, text string) string {
+	c, ok := levelColors[level]
+	if !f.color || !ok {
+		return text
+	}
+	return c + text + colorReset
+}
+
+// otherKeys returns the sorted keys of the entry that are not fixed keys.
+func otherKeys(e entry) []string {
+	keys := make([]string, 0, len(e.fields))
+	for k := range e.fields {
+		switch k {
+		case "service", "ts", "level", "traceID", "caller", "msg":
+			continue
+		}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// quote quotes the value if it would otherwise be ambiguous in logfmt.
+func quote(v string) string {
+	if v == "" || strings.ContainsAny(v, " =\"\t\n") {
+		return strconv.Quote(v)
+	}
+	return v
+}
@@ -0,0 +1,51 @@
+package main
+
+import "testing"
+
+func TestFormat(t *testing.T) {
+	e := line("service", "SALES", "ts", "2022-10-01T12:00:00.000Z", "level", "info",
+		"traceID", "abc", "caller", "mid/logger.go:30", "msg", "request completed", "statusCode", 200.0, "path", "/v1/users x")
+	e.raw = `{"msg":"request completed"}`
+
+	t.Log("Given the need to format log lines.")
+
+	tt := []struct {
+		mode  string
+		color bool
+		exp   string
+	}{
+		{"pretty", false, "SALES: 2022-10-01T12:00:00.000Z: info: abc: mid/logger.go:30: request completed: path[/v1/users x]: statusCode[200]"},
+		{"logfmt", false, `service=SALES ts=2022-10-01T12:00:00.000Z level=info traceID=abc caller=mid/logger.go:30 msg="request completed" path="/v1/users x" statusCode=200`},
+		{"logfmt", true, "service=SALES ts=2022-10-01T12:00:00.000Z level=" + colorCyan + "info" + colorReset + ` traceID=abc caller=mid/logger.go:30 msg="request completed" path="/v1/users x" statusCode=200`},
+		{"json", false, `{"msg":"request completed"}`},
+	}
+
+	for i, tc := range tt {
+		t.Logf("\tTest %d:\tWhen formatting as %s, color %t.", i, tc.mode, tc.color)
+		{
+			f, err := newFormatter(tc.mode, tc.color)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould construct the formatter: %s", failed, i, err)
+			}
+
+			if got := f.format(e); got != tc.exp {
+				t.Fatalf("\t%s\tTest %d:\tShould format the line:\ngot %q\nexp %q", failed, i, got, tc.exp)
+			}
+			t.Logf("\t%s\tTest %d:\tShould format the line.", success, i)
+		}
+	}
+
+	t.Logf("\tTest %d:\tWhen the line has no trace ID.", len(tt))
+	{
+		f, _ := newFormatter("pretty", false)
+		if got := f.format(line("msg", "startup")); got != ": : : "+zeroTraceID+": : startup" {
+			t.Fatalf("\t%s\tTest %d:\tShould show the zero trace ID: got %q.", failed, len(tt), got)
+		}
+		t.Logf("\t%s\tTest %d:\tShould show the zero trace ID.", success, len(tt))
+	}
+
+	if _, err := newFormatter("xml", false); err == nil {
+		t.Fatalf("\t%s\tShould reject unknown formats.", failed)
+	}
+	t.Logf("\t%s\tShould reject unknown formats.", success)
+}
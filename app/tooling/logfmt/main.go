@@ -1,3 +1,4 @@
+// This program takes the structured log output and makes it readable.
 package main
 
 import (
@@ -7,69 +8,139 @@ import (
 	"fmt"
 	"log"
 	"os"
-	"strings"
+	"time"
 )
 
-var service string
+var (
+	service     string
+	level       string
+	traceID     string
+	match       string
+	since       string
+	until       string
+	format      string
+	color       bool
+	followTrace bool
+	statsMode   bool
+	statsEvery  time.Duration
+)
 
 func init() {
 	flag.StringVar(&service, "service", "", "filter which service to see")
+	flag.StringVar(&level, "level", "", "minimum level to see: debug, info, warn, error")
+	flag.StringVar(&traceID, "trace", "", "filter which trace ID to see")
+	flag.StringVar(&match, "match", "", "regular expression the message must match")
+	flag.StringVar(&since, "since", "", "hide lines before this time (RFC3339) or duration ago (15m)")
+	flag.StringVar(&until, "until", "", "hide lines after this time (RFC3339) or duration ago (15m)")
+	flag.StringVar(&format, "format", "pretty", "output format: pretty, logfmt or json")
+	flag.BoolVar(&color, "color", isTerminal(os.Stdout), "colorize the output by level")
+	flag.BoolVar(&followTrace, "follow-trace", false, "only show lines of requests whose trace ID had an error")
+	flag.BoolVar(&statsMode, "stats", false, "print request, error and query statistics instead of the lines, queries are only logged at debug level")
+	flag.DurationVar(&statsEvery, "stats-interval", 0, "print the statistics periodically, not only at the end")
 }
 
 func main() {
 	flag.Parse()
-	var b strings.Builder
+
+	f, err := newFilter(time.Now())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	out, err := newFormatter(format, color)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	var ft *traceFollower
+	if followTrace {
+		ft = newTraceFollower()
+	}
+
+	var st *stats
+	if statsMode {
+		st = newStats()
+		defer st.print(os.Stdout)
+
+		if statsEvery > 0 {
+			go func() {
+				for range time.Tick(statsEvery) {
+					st.print(os.Stdout)
+				}
+			}()
+		}
+	}
+
+	w := bufio.NewWriter(os.Stdout)
+	defer w.Flush()
 
 	// Scan standard input for log data per line.
 	scanner := bufio.NewScanner(os.Stdin)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
 	for scanner.Scan() {
 		s := scanner.Text()
 
-		// Convert the JSON o a map for processing.
+		// Convert the JSON to a map for processing.
 		m := make(map[string]interface{})
-		err := json.Unmarshal([]byte(s), &m)
-
-		if err != nil {
-			if service == "" {
-				fmt.Println(s)
+		if err := json.Unmarshal([]byte(s), &m); err != nil {
+			if f.empty() && ft == nil && st == nil {
+				fmt.Fprintln(w, s)
+				w.Flush()
 			}
 			continue
 		}
 
-		if service != "" && m["service"] != service {
+		if !f.keep(m) {
 			continue
 		}
 
-		traceID := "00000000-0000-0000-0000-000000000000"
+		e := entry{raw: s, fields: m}
 
-		if v, ok := m["traceID"]; ok {
-			traceID = fmt.Sprintf("%v", v)
-		}
-
-		b.Reset()
-		b.WriteString(fmt.Sprintf("%s: %s: %s: %s: %s: %s: ",
-			m["service"],
-			m["ts"],
-			m["level"],
-			traceID,
-			m["caller"],
-			m["msg"],
-		))
-
-		for k, v := range m {
-			switch k {
-			case "service", "ts", "level", "traceid", "caller", "msg":
-				continue
+		switch {
+		case st != nil:
+			st.add(e)
+			continue
+		case ft != nil:
+			for _, e := range ft.add(e) {
+				fmt.Fprintln(w, out.format(e))
 			}
-
-			b.WriteString(fmt.Sprintf("%s[%v]", k, v))
+		default:
+			fmt.Fprintln(w, out.format(e))
 		}
 
-		out := b.String()
-		fmt.Println(out[:len(out)-2])
+		// Flush per line so output keeps up with a live stream.
+		w.Flush()
 	}
 
 	if err := scanner.Err(); err != nil {
 		log.Println(err)
 	}
 }
+
+// isTerminal reports whether the file is a character device.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// entry is a single parsed log line.
+type entry struct {
+	raw    string
+	fields map[string]interface{}
+}
+
+// str returns the field as a string, or an empty string if it is missing.
+func (e entry) str(key string) string {
+	v, ok := e.fields[key]
+	if !ok {
+		return ""
+	}
+	return fmt.Sprintf("%v", v)
+}
@@ -0,0 +1,300 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// Number of rows shown for the error and slow query tables.
+const (
+	topErrors  = 10
+	topQueries = 10
+)
+
+// maxErrorKinds is the number of distinct errors counted, the errors seen
+// after that many are counted as otherErrors.
+const (
+	maxErrorKinds = 1000
+	otherErrors   = "other"
+)
+
+// Parts of error messages that change from one occurrence to the next, like
+// the ID[...] the service logs, UUIDs and numbers.
+var (
+	bracketedValue = regexp.MustCompile(`\[[^\]]*\]`)
+	uuidValue      = regexp.MustCompile(`[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}`)
+	numberValue    = regexp.MustCompile(`\b\d+\b`)
+)
+
+// stats aggregates request, error and database query information from a
+// stream of log lines.
+type stats struct {
+	mu       sync.Mutex
+	lines    int
+	requests map[string]*pathStats
+	errors   map[string]int
+
+	// The service logs its queries at debug level, so they are only seen
+	// when it runs at that level.
+	queryLines int
+	queries    []query
+}
+
+// pathStats holds the aggregates for a single method and path.
+type pathStats struct {
+	statusCodes map[string]int
+	latencies   histogram
+}
+
+// query is a single database query and how long it took.
+type query struct {
+	name  string
+	query string
+	since time.Duration
+}
+
+// newStats constructs an empty stats value.
+func newStats() *stats {
+	return &stats{
+		requests: make(map[string]*pathStats),
+		errors:   make(map[string]int),
+	}
+}
+
+// add records the information carried by the log entry.
+func (s *stats) add(e entry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lines++
+
+	msg := e.str("msg")
+
+	switch {
+	case msg == "request completed":
+		path := e.str("route")
+		if path == "" {
+			path = e.str("path")
+		}
+		key := e.str("method") + " " + path
+
+		ps, exists := s.requests[key]
+		if !exists {
+			ps = &pathStats{statusCodes: make(map[string]int)}
+			s.requests[key] = ps
+		}
+		ps.statusCodes[e.str("statusCode")]++
+		if d, ok := duration(e.fields["since"]); ok {
+			ps.latencies.add(d)
+		}
+
+	case strings.HasPrefix(msg, "database."):
+		d, ok := duration(e.fields["since"])
+		if !ok {
+			break
+		}
+		s.queryLines++
+		s.queries = append(s.queries, query{name: msg, query: e.str("query"), since: d})
+		sort.Slice(s.queries, func(i, j int) bool { return s.queries[i].since > s.queries[j].since })
+		if len(s.queries) > topQueries {
+			s.queries = s.queries[:topQueries]
+		}
+	}
+
+	if levels[e.str("level")] >= levels["error"] {
+		key := e.str("ERROR")
+		if key == "" {
+			key = msg
+		}
+		key = errorKind(key)
+		if _, exists := s.errors[key]; !exists && len(s.errors) >= maxErrorKinds {
+			key = otherErrors
+		}
+		s.errors[key]++
+	}
+}
+
+// errorKind returns the error message without the values that change from
+// one occurrence to the next, so occurrences of the same error are counted
+// together.
+func errorKind(msg string) string {
+	msg = bracketedValue.ReplaceAllString(msg, "[...]")
+	msg = uuidValue.ReplaceAllString(msg, "<id>")
+	return numberValue.ReplaceAllString(msg, "<n>")
+}
+
+// print writes the summary of everything recorded so far.
+func (s *stats) print(w io.Writer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	fmt.Fprintf(w, "=== %s: %d lines\n\n", time.Now().Format(time.RFC3339), s.lines)
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+
+	fmt.Fprintln(tw, "REQUEST\tCOUNT\tSTATUS\tP50\tP90\tP99\tMAX")
+	for _, key := range sortedKeys(s.requests) {
+		ps := s.requests[key]
+
+		codes := make([]string, 0, len(ps.statusCodes))
+		count := 0
+		for code, n := range ps.statusCodes {
+			codes = append(codes, fmt.Sprintf("%s:%d", code, n))
+			count += n
+		}
+		sort.Strings(codes)
+
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%v\t%v\t%v\t%v\n",
+			key,
+			count,
+			strings.Join(codes, " "),
+			ps.latencies.percentile(50),
+			ps.latencies.percentile(90),
+			ps.latencies.percentile(99),
+			ps.latencies.max,
+		)
+	}
+	fmt.Fprintln(tw)
+
+	type errorCount struct {
+		msg   string
+		count int
+	}
+	errs := make([]errorCount, 0, len(s.errors))
+	for msg, n := range s.errors {
+		errs = append(errs, errorCount{msg, n})
+	}
+	sort.Slice(errs, func(i, j int) bool {
+		if errs[i].count == errs[j].count {
+			return errs[i].msg < errs[j].msg
+		}
+		return errs[i].count > errs[j].count
+	})
+	if len(errs) > topErrors {
+		errs = errs[:topErrors]
+	}
+
+	fmt.Fprintln(tw, "ERROR\tCOUNT")
+	for _, e := range errs {
+		fmt.Fprintf(tw, "%s\t%d\n", e.msg, e.count)
+	}
+	fmt.Fprintln(tw)
+
+	fmt.Fprintln(tw, "SLOWEST QUERY\tSINCE\tQUERY")
+	for _, q := range s.queries {
+		fmt.Fprintf(tw, "%s\t%v\t%s\n", q.name, q.since, q.query)
+	}
+
+	tw.Flush()
+	if s.queryLines == 0 {
+		fmt.Fprintln(w, "no query lines seen, the service logs them at debug level: PUT {\"level\":\"debug\"} to /debug/loglevel")
+	}
+	fmt.Fprintln(w)
+}
+
+// duration converts the since field of a log line into a duration. The zap
+// production encoder writes durations as seconds, but strings like "1.5ms"
+// are accepted too.
+func duration(v interface{}) (time.Duration, bool) {
+	switch d := v.(type) {
+	case float64:
+		return time.Duration(d * float64(time.Second)), true
+	case string:
+		if pd, err := time.ParseDuration(d); err == nil {
+			return pd, true
+		}
+		if f, err := strconv.ParseFloat(d, 64); err == nil {
+			return time.Duration(f * float64(time.Second)), true
+		}
+	}
+	return 0, false
+}
+
+// Histogram buckets grow by histogramGrowth from histogramMin, so a
+// percentile is off by at most that factor whatever the number of values.
+const (
+	histogramMin    = time.Microsecond
+	histogramGrowth = 1.05
+)
+
+// histogram counts durations in exponentially growing buckets, so its size
+// does not depend on how many durations were added.
+type histogram struct {
+	counts map[int]int
+	total  int
+	max    time.Duration
+}
+
+// bucket returns the index of the bucket holding the duration.
+func bucket(d time.Duration) int {
+	if d <= histogramMin {
+		return 0
+	}
+	return int(math.Ceil(math.Log(float64(d)/float64(histogramMin)) / math.Log(histogramGrowth)))
+}
+
+// upperBound returns the largest duration of the bucket.
+func upperBound(b int) time.Duration {
+	return time.Duration(float64(histogramMin) * math.Pow(histogramGrowth, float64(b)))
+}
+
+// add counts the duration.
+func (h *histogram) add(d time.Duration) {
+	if h.counts == nil {
+		h.counts = make(map[int]int)
+	}
+	h.counts[bucket(d)]++
+	h.total++
+	if d > h.max {
+		h.max = d
+	}
+}
+
+// percentile returns the p-th percentile using the nearest-rank method, as
+// the upper bound of the bucket holding it, capped to the largest duration.
+func (h *histogram) percentile(p float64) time.Duration {
+	if h.total == 0 {
+		return 0
+	}
+
+	rank := int(math.Ceil(p / 100 * float64(h.total)))
+	if rank < 1 {
+		rank = 1
+	}
+
+	buckets := make([]int, 0, len(h.counts))
+	for b := range h.counts {
+		buckets = append(buckets, b)
+	}
+	sort.Ints(buckets)
+
+	seen := 0
+	for _, b := range buckets {
+		seen += h.counts[b]
+		if seen >= rank {
+			if d := upperBound(b); d < h.max {
+				return d
+			}
+			return h.max
+		}
+	}
+	return h.max
+}
+
+// sortedKeys returns the keys of the map in order.
+func sortedKeys(m map[string]*pathStats) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// readEntries reads the log lines of the file the way main does.
+func readEntries(t *testing.T, path string) []entry {
+	t.Helper()
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("\t%s\tShould be able to open %s: %s", failed, path, err)
+	}
+	defer f.Close()
+
+	var es []entry
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		m := make(map[string]interface{})
+		if err := json.Unmarshal(sc.Bytes(), &m); err != nil {
+			t.Fatalf("\t%s\tShould be able to decode %q: %s", failed, sc.Text(), err)
+		}
+		es = append(es, entry{raw: sc.Text(), fields: m})
+	}
+	return es
+}
+
+func TestStats(t *testing.T) {
+	t.Log("Given the need to aggregate the output of the service.")
+	{
+		t.Log("\tTest 0:\tWhen the service runs at debug level.")
+		{
+			st := newStats()
+			for _, e := range readEntries(t, "testdata/service.log") {
+				st.add(e)
+			}
+
+			ps := st.requests["GET /v1/users/:id"]
+			if ps == nil || ps.statusCodes["400"] != 2 || ps.latencies.total != 2 {
+				t.Fatalf("\t%s\tTest 0:\tShould group requests by route: got %+v.", failed, ps)
+			}
+			t.Logf("\t%s\tTest 0:\tShould group requests by route.", success)
+
+			if ps := st.requests["GET /v1/unknown"]; ps == nil || ps.statusCodes["404"] != 1 {
+				t.Fatalf("\t%s\tTest 0:\tShould fall back to the path without a route: got %+v.", failed, ps)
+			}
+			t.Logf("\t%s\tTest 0:\tShould fall back to the path without a route.", success)
+
+			if n := st.errors["query: ID is not in its proper form"]; n != 2 {
+				t.Fatalf("\t%s\tTest 0:\tShould count the errors: got %d.", failed, n)
+			}
+			t.Logf("\t%s\tTest 0:\tShould count the errors.", success)
+
+			if len(st.queries) != 2 || st.queries[0].name != "database.NamedQueryStruct" {
+				t.Fatalf("\t%s\tTest 0:\tShould order the queries by duration: got %+v.", failed, st.queries)
+			}
+			t.Logf("\t%s\tTest 0:\tShould order the queries by duration.", success)
+
+			var buf bytes.Buffer
+			st.print(&buf)
+			if !strings.Contains(buf.String(), "SELECT * FROM users") || strings.Contains(buf.String(), "no query lines") {
+				t.Fatalf("\t%s\tTest 0:\tShould print the slowest queries:\n%s", failed, buf.String())
+			}
+			t.Logf("\t%s\tTest 0:\tShould print the slowest queries.", success)
+		}
+
+		t.Log("\tTest 1:\tWhen the service runs at info level.")
+		{
+			st := newStats()
+			for _, e := range readEntries(t, "testdata/service.log") {
+				if e.str("level") != "debug" {
+					st.add(e)
+				}
+			}
+
+			var buf bytes.Buffer
+			st.print(&buf)
+			if !strings.Contains(buf.String(), "no query lines seen") {
+				t.Fatalf("\t%s\tTest 1:\tShould explain why there are no queries:\n%s", failed, buf.String())
+			}
+			t.Logf("\t%s\tTest 1:\tShould explain why there are no queries.", success)
+		}
+
+		t.Log("\tTest 2:\tWhen the errors carry IDs.")
+		{
+			st := newStats()
+			for i := 0; i < maxErrorKinds+10; i++ {
+				st.add(entry{fields: map[string]interface{}{
+					"level": "error",
+					"ERROR": fmt.Sprintf("query: ID[%d]: not found", i),
+				}})
+				st.add(entry{fields: map[string]interface{}{
+					"level": "error",
+					"ERROR": fmt.Sprintf("update: userId 45b5fbd3-755f-4379-8f07-%012d failed after %d attempts", i, i%3),
+				}})
+			}
+
+			if n := st.errors["query: ID[...]: not found"]; n != maxErrorKinds+10 {
+				t.Fatalf("\t%s\tTest 2:\tShould count the errors without their IDs: got %d in %d kinds.", failed, n, len(st.errors))
+			}
+			if n := st.errors["update: userId <id> failed after <n> attempts"]; n != maxErrorKinds+10 {
+				t.Fatalf("\t%s\tTest 2:\tShould count the errors without their IDs: got %d in %d kinds.", failed, n, len(st.errors))
+			}
+			t.Logf("\t%s\tTest 2:\tShould count the errors without their IDs.", success)
+
+			st = newStats()
+			for i := 0; i < maxErrorKinds+10; i++ {
+				st.add(entry{fields: map[string]interface{}{
+					"level": "error",
+					"ERROR": "failure " + strings.Repeat("x", i),
+				}})
+			}
+
+			if len(st.errors) != maxErrorKinds+1 || st.errors[otherErrors] != 10 {
+				t.Fatalf("\t%s\tTest 2:\tShould fold the errors over the limit into %q: got %d kinds, %d other.", failed, otherErrors, len(st.errors), st.errors[otherErrors])
+			}
+			t.Logf("\t%s\tTest 2:\tShould fold the errors over the limit into %q.", success, otherErrors)
+		}
+	}
+}
+
+func TestHistogram(t *testing.T) {
+	t.Log("Given the need to compute latency percentiles of long streams.")
+	{
+		t.Log("\tTest 0:\tWhen adding many durations.")
+		{
+			var h histogram
+			for i := 1; i <= 100000; i++ {
+				h.add(time.Duration(i) * time.Microsecond)
+			}
+
+			for _, p := range []float64{50, 90, 99} {
+				exp := float64(p) / 100 * 100000 * float64(time.Microsecond)
+				got := float64(h.percentile(p))
+				if math.Abs(got-exp)/exp > histogramGrowth-1 {
+					t.Fatalf("\t%s\tTest 0:\tShould get the p%.0f within the bucket error: got %v, exp %v.", failed, p, time.Duration(got), time.Duration(exp))
+				}
+			}
+			t.Logf("\t%s\tTest 0:\tShould get the percentiles within the bucket error.", success)
+
+			if h.max != 100*time.Millisecond || h.percentile(100) != h.max {
+				t.Fatalf("\t%s\tTest 0:\tShould keep the exact maximum: got %v.", failed, h.max)
+			}
+			t.Logf("\t%s\tTest 0:\tShould keep the exact maximum.", success)
+
+			if len(h.counts) > 300 {
+				t.Fatalf("\t%s\tTest 0:\tShould use a bounded number of buckets: got %d.", failed, len(h.counts))
+			}
+			t.Logf("\t%s\tTest 0:\tShould use a bounded number of buckets.", success)
+		}
+
+		t.Log("\tTest 1:\tWhen no duration was added.")
+		{
+			var h histogram
+			if h.percentile(50) != 0 {
+				t.Fatalf("\t%s\tTest 1:\tShould return zero.", failed)
+			}
+			t.Logf("\t%s\tTest 1:\tShould return zero.", success)
+		}
+	}
+}
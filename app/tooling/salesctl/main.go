@@ -0,0 +1,136 @@
+// This program operates a running sales service over its HTTP API.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/mohammadhsn/ultimate-service/business/client"
+)
+
+// Exit codes, so scripts can tell failures apart.
+const (
+	exitOK       = 0
+	exitError    = 1
+	exitUsage    = 2
+	exitNotFound = 3
+	exitInvalid  = 4
+	exitConflict = 5
+)
+
+const usage = `usage: salesctl [flags] <resource> <action> [arguments]
+
+resources and actions:
+  users list
+  users get <id>
+  users create -name <name> -email <email> [-password-stdin] [-roles a,b]
+  users update <id> [-name <name>] [-email <email>] [-password-stdin] [-roles a,b] [-version <n>]
+  users delete <id>
+
+Passwords are read from stdin with -password-stdin. Without it, users create
+uses the password in SALESCTL_PASSWORD.
+
+Listing users needs the admin key of the service in SALESCTL_ADMIN_KEY.
+
+The API has no product, sale or token endpoints yet, so login, products and
+sales are not available.
+
+exit codes:
+  0 success, 1 error, 2 usage, 3 not found, 4 invalid request, 5 conflict
+
+flags:`
+
+// errUsage reports a command line that can not be run.
+var errUsage = errors.New("invalid usage")
+
+func main() {
+	fs := flag.NewFlagSet("salesctl", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), usage)
+		fs.PrintDefaults()
+	}
+
+	baseURL := fs.String("url", envOr("SALESCTL_URL", "http://localhost:3000"), "address of the sales API")
+	output := fs.String("output", "table", "output format: table, json or csv")
+	timeout := fs.Duration("timeout", 30*time.Second, "time allowed for the whole command")
+	retries := fs.Int("retries", 2, "retries of requests failing with transient errors")
+
+	if err := fs.Parse(os.Args[1:]); err != nil {
+		os.Exit(exitUsage)
+	}
+
+	out, err := newPrinter(*output, os.Stdout)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		fs.Usage()
+		os.Exit(exitUsage)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	c := client.New(client.Config{
+		BaseURL:    *baseURL,
+		AdminKey:   os.Getenv("SALESCTL_ADMIN_KEY"),
+		MaxRetries: *retries,
+	})
+
+	err = run(ctx, c, out, os.Stdin, fs.Args())
+	if errors.Is(err, errUsage) {
+		fmt.Fprintln(os.Stderr, err)
+		fs.Usage()
+		os.Exit(exitUsage)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	}
+	os.Exit(exitCode(err))
+}
+
+// run dispatches the command line to the command of the resource.
+func run(ctx context.Context, c *client.Client, out printer, stdin io.Reader, args []string) error {
+	if len(args) < 2 {
+		return fmt.Errorf("%w: missing resource or action", errUsage)
+	}
+
+	switch args[0] {
+	case "users":
+		return users(ctx, c, out, stdin, args[1], args[2:])
+	case "login", "products", "sales":
+		return fmt.Errorf("%s: not supported by the API yet", args[0])
+	}
+
+	return fmt.Errorf("%w: unknown resource %q", errUsage, args[0])
+}
+
+// exitCode maps the result of a command to the exit code of the program.
+func exitCode(err error) int {
+	if err == nil {
+		return exitOK
+	}
+
+	switch client.StatusCode(err) {
+	case http.StatusNotFound:
+		return exitNotFound
+	case http.StatusBadRequest, http.StatusUnprocessableEntity:
+		return exitInvalid
+	case http.StatusConflict, http.StatusPreconditionFailed, http.StatusPreconditionRequired:
+		return exitConflict
+	}
+
+	return exitError
+}
+
+// envOr returns the value of the environment variable, or def when unset.
+func envOr(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/mohammadhsn/ultimate-service/business/client"
+	"github.com/mohammadhsn/ultimate-service/business/data/store/user"
+)
+
+const (
+	success = "\u2713"
+	failed  = "\u2717"
+)
+
+// fakeAPI answers the user routes of the sales API and remembers the last
+// request body and If-Match header it received.
+type fakeAPI struct {
+	mu      sync.Mutex
+	body    []byte
+	ifMatch string
+}
+
+func (api *fakeAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	api.mu.Lock()
+	api.body = nil
+	if r.Body != nil {
+		var buf bytes.Buffer
+		buf.ReadFrom(r.Body)
+		api.body = buf.Bytes()
+	}
+	api.ifMatch = r.Header.Get("If-Match")
+	api.mu.Unlock()
+
+	usr := user.User{ID: "1", Name: "Bill", Email: "bill@example.com", Roles: []string{"ADMIN", "USER"}, Version: 3}
+
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/v1/users/export":
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		json.NewEncoder(w).Encode(usr)
+	case r.Method == http.MethodGet && r.URL.Path == "/v1/users/missing":
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"error":"not found"}`))
+	case r.Method == http.MethodGet:
+		json.NewEncoder(w).Encode(usr)
+	case r.Method == http.MethodPost:
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(usr)
+	case r.Method == http.MethodPut:
+		json.NewEncoder(w).Encode(usr)
+	case r.Method == http.MethodDelete:
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// password returns the password and its confirmation sent in the last
+// request body.
+func (api *fakeAPI) password() (string, string) {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+
+	// The confirmation is named differently for new and updated users.
+	var body struct {
+		Password        *string `json:"password"`
+		PasswordConfirm *string `json:"password_confirm"`
+		UpdateConfirm   *string `json:"passwordConfirm"`
+	}
+	json.Unmarshal(api.body, &body)
+	if body.PasswordConfirm == nil {
+		body.PasswordConfirm = body.UpdateConfirm
+	}
+
+	if body.Password == nil || body.PasswordConfirm == nil {
+		return "", ""
+	}
+	return *body.Password, *body.PasswordConfirm
+}
+
+func TestRun(t *testing.T) {
+	var api fakeAPI
+	srv := httptest.NewServer(&api)
+	t.Cleanup(srv.Close)
+
+	c := client.New(client.Config{BaseURL: srv.URL})
+
+	t.Setenv("SALESCTL_PASSWORD", "")
+
+	t.Log("Given the need to operate the service from the command line.")
+
+	tt := []struct {
+		name   string
+		args   []string
+		format string
+		stdin  string
+		env    string
+		exit   int
+		usage  bool
+		output string
+	}{
+		{name: "listing a single user", args: []string{"users", "list"}, format: "json", output: "[\n  {"},
+		{name: "getting a user", args: []string{"users", "get", "1"}, format: "json", output: "{\n"},
+		{name: "getting a missing user", args: []string{"users", "get", "missing"}, format: "json", exit: exitNotFound},
+		{name: "listing users as CSV", args: []string{"users", "list"}, format: "csv", output: "ID,NAME,EMAIL,ROLES,VERSION,UPDATED\n1,Bill,bill@example.com,\"ADMIN,USER\",3,"},
+		{name: "listing users as a table", args: []string{"users", "list"}, format: "table", output: "ID  NAME  EMAIL"},
+		{name: "creating a user with the password on stdin", args: []string{"users", "create", "-name", "Bill", "-email", "bill@example.com", "-password-stdin"}, format: "json", stdin: "s3cret\n", output: "{\n"},
+		{name: "creating a user with the password in the environment", args: []string{"users", "create", "-name", "Bill", "-email", "bill@example.com"}, format: "json", env: "s3cret", output: "{\n"},
+		{name: "creating a user with an empty stdin", args: []string{"users", "create", "-name", "Bill", "-password-stdin"}, format: "json", exit: exitUsage, usage: true},
+		{name: "passing the password as a flag", args: []string{"users", "create", "-password", "s3cret"}, format: "json", exit: exitUsage, usage: true},
+		{name: "updating the password", args: []string{"users", "update", "1", "-password-stdin", "-version", "3"}, format: "json", stdin: "s3cret\r\n", output: "{\n"},
+		{name: "deleting a user", args: []string{"users", "delete", "1"}, format: "json"},
+		{name: "running an unknown action", args: []string{"users", "rename", "1"}, format: "json", exit: exitUsage, usage: true},
+		{name: "using a resource the API does not have", args: []string{"products", "list"}, format: "json", exit: exitError},
+	}
+
+	for i, tc := range tt {
+		t.Logf("\tTest %d:\tWhen %s.", i, tc.name)
+		{
+			t.Setenv("SALESCTL_PASSWORD", tc.env)
+
+			var out bytes.Buffer
+			p, err := newPrinter(tc.format, &out)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould construct the printer: %s", failed, i, err)
+			}
+
+			err = run(context.Background(), c, p, strings.NewReader(tc.stdin), tc.args)
+
+			code := exitCode(err)
+			if errors.Is(err, errUsage) {
+				code = exitUsage
+			}
+			if code != tc.exit || errors.Is(err, errUsage) != tc.usage {
+				t.Fatalf("\t%s\tTest %d:\tShould exit with %d: got %d: %v.", failed, i, tc.exit, code, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould exit with %d.", success, i, tc.exit)
+
+			if !strings.HasPrefix(out.String(), tc.output) {
+				t.Fatalf("\t%s\tTest %d:\tShould print the result:\ngot %q\nexp %q", failed, i, out.String(), tc.output)
+			}
+			t.Logf("\t%s\tTest %d:\tShould print the result.", success, i)
+
+			if tc.stdin != "" || tc.env != "" {
+				if pw, confirm := api.password(); pw != "s3cret" || confirm != "s3cret" {
+					t.Fatalf("\t%s\tTest %d:\tShould send the password: got %q, %q.", failed, i, pw, confirm)
+				}
+				t.Logf("\t%s\tTest %d:\tShould send the password.", success, i)
+			}
+		}
+	}
+
+	t.Logf("\tTest %d:\tWhen updating without a new password.", len(tt))
+	{
+		var out bytes.Buffer
+		p, _ := newPrinter("json", &out)
+		t.Setenv("SALESCTL_PASSWORD", "s3cret")
+
+		if err := run(context.Background(), c, p, strings.NewReader(""), []string{"users", "update", "1", "-name", "Jack", "-version", "3"}); err != nil {
+			t.Fatalf("\t%s\tTest %d:\tShould update the user: %v.", failed, len(tt), err)
+		}
+		t.Logf("\t%s\tTest %d:\tShould update the user.", success, len(tt))
+
+		if pw, _ := api.password(); pw != "" || api.ifMatch != `"3"` {
+			t.Fatalf("\t%s\tTest %d:\tShould leave the password alone and send the version: got %q, %s.", failed, len(tt), pw, api.ifMatch)
+		}
+		t.Logf("\t%s\tTest %d:\tShould leave the password alone and send the version.", success, len(tt))
+	}
+}
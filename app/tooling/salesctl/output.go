@@ -0,0 +1,94 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/mohammadhsn/ultimate-service/business/data/store/user"
+)
+
+// printer writes the results of commands in one of the output formats.
+type printer struct {
+	format string
+	w      io.Writer
+}
+
+// newPrinter constructs a printer for the format.
+func newPrinter(format string, w io.Writer) (printer, error) {
+	switch format {
+	case "table", "json", "csv":
+		return printer{format: format, w: w}, nil
+	}
+	return printer{}, fmt.Errorf("unknown output format %q", format)
+}
+
+// users prints a list of users. As JSON it is always an array, whatever the
+// number of users.
+func (p printer) users(usrs []user.User) error {
+	if p.format == "json" {
+		if usrs == nil {
+			usrs = []user.User{}
+		}
+		return p.json(usrs)
+	}
+
+	return p.userTable(usrs)
+}
+
+// user prints a single user. As JSON it is an object.
+func (p printer) user(usr user.User) error {
+	if p.format == "json" {
+		return p.json(usr)
+	}
+
+	return p.userTable([]user.User{usr})
+}
+
+// json prints the value as indented JSON.
+func (p printer) json(v interface{}) error {
+	enc := json.NewEncoder(p.w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// userTable prints the users as a table or as CSV.
+func (p printer) userTable(usrs []user.User) error {
+
+	header := []string{"ID", "NAME", "EMAIL", "ROLES", "VERSION", "UPDATED"}
+	rows := make([][]string, len(usrs))
+	for i, usr := range usrs {
+		rows[i] = []string{
+			usr.ID,
+			usr.Name,
+			usr.Email,
+			strings.Join(usr.Roles, ","),
+			strconv.Itoa(usr.Version),
+			usr.DateUpdated.UTC().Format(time.RFC3339),
+		}
+	}
+
+	return p.table(header, rows)
+}
+
+// table prints the rows as an aligned table or as CSV.
+func (p printer) table(header []string, rows [][]string) error {
+	if p.format == "csv" {
+		w := csv.NewWriter(p.w)
+		w.Write(header)
+		w.WriteAll(rows)
+		return w.Error()
+	}
+
+	w := tabwriter.NewWriter(p.w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, strings.Join(header, "\t"))
+	for _, row := range rows {
+		fmt.Fprintln(w, strings.Join(row, "\t"))
+	}
+	return w.Flush()
+}
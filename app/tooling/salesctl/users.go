@@ -0,0 +1,184 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/mohammadhsn/ultimate-service/business/client"
+	"github.com/mohammadhsn/ultimate-service/business/data/store/user"
+)
+
+// users runs an action on the users. Passwords are read from stdin.
+func users(ctx context.Context, c *client.Client, out printer, stdin io.Reader, action string, args []string) error {
+	switch action {
+	case "list":
+		var usrs []user.User
+		if err := c.ExportUsers(ctx, func(usr user.User) error {
+			usrs = append(usrs, usr)
+			return nil
+		}); err != nil {
+			return err
+		}
+		return out.users(usrs)
+
+	case "get":
+		id, err := oneID(args)
+		if err != nil {
+			return err
+		}
+		usr, err := c.QueryUserByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		return out.user(usr)
+
+	case "create":
+		return createUser(ctx, c, out, stdin, args)
+
+	case "update":
+		return updateUser(ctx, c, out, stdin, args)
+
+	case "delete":
+		id, err := oneID(args)
+		if err != nil {
+			return err
+		}
+		return c.DeleteUser(ctx, id)
+	}
+
+	return fmt.Errorf("%w: unknown users action %q", errUsage, action)
+}
+
+// createUser adds the user described by the flags. The password is read
+// from stdin with -password-stdin, or else from SALESCTL_PASSWORD, so it
+// never shows up in the shell history or the process list.
+func createUser(ctx context.Context, c *client.Client, out printer, stdin io.Reader, args []string) error {
+	fs := flag.NewFlagSet("users create", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	name := fs.String("name", "", "name of the user")
+	email := fs.String("email", "", "email of the user")
+	passwordStdin := fs.Bool("password-stdin", false, "read the password of the user from stdin")
+	roles := fs.String("roles", "USER", "comma separated roles of the user")
+
+	if err := fs.Parse(args); err != nil {
+		return fmt.Errorf("%w: %s", errUsage, err)
+	}
+
+	password := os.Getenv("SALESCTL_PASSWORD")
+	if *passwordStdin {
+		var err error
+		if password, err = readPassword(stdin); err != nil {
+			return err
+		}
+	}
+
+	usr, err := c.CreateUser(ctx, user.NewUser{
+		Name:            *name,
+		Email:           *email,
+		Roles:           splitList(*roles),
+		Password:        password,
+		PasswordConfirm: password,
+	})
+	if err != nil {
+		return err
+	}
+
+	return out.user(usr)
+}
+
+// updateUser changes the fields of the user set by the flags. Without a
+// version, the changes apply to the current version of the user. The new
+// password is read from stdin with -password-stdin.
+func updateUser(ctx context.Context, c *client.Client, out printer, stdin io.Reader, args []string) error {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return fmt.Errorf("%w: missing user ID", errUsage)
+	}
+	id := args[0]
+
+	fs := flag.NewFlagSet("users update", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	name := fs.String("name", "", "new name of the user")
+	email := fs.String("email", "", "new email of the user")
+	passwordStdin := fs.Bool("password-stdin", false, "read the new password of the user from stdin")
+	roles := fs.String("roles", "", "new comma separated roles of the user")
+	version := fs.Int("version", 0, "version of the user the changes are based on")
+
+	if err := fs.Parse(args[1:]); err != nil {
+		return fmt.Errorf("%w: %s", errUsage, err)
+	}
+
+	var uu user.UpdateUser
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "name":
+			uu.Name = name
+		case "email":
+			uu.Email = email
+		case "roles":
+			uu.Roles = splitList(*roles)
+		}
+	})
+
+	if *passwordStdin {
+		password, err := readPassword(stdin)
+		if err != nil {
+			return err
+		}
+		uu.Password = &password
+		uu.PasswordConfirm = &password
+	}
+
+	if *version == 0 {
+		usr, err := c.QueryUserByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		*version = usr.Version
+	}
+
+	usr, err := c.UpdateUser(ctx, id, uu, *version)
+	if err != nil {
+		return err
+	}
+
+	return out.user(usr)
+}
+
+// readPassword reads a password from the first line of the reader.
+func readPassword(r io.Reader) (string, error) {
+	line, err := bufio.NewReader(r).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", fmt.Errorf("reading password: %w", err)
+	}
+
+	password := strings.TrimRight(line, "\r\n")
+	if password == "" {
+		return "", fmt.Errorf("%w: empty password on stdin", errUsage)
+	}
+	return password, nil
+}
+
+// oneID returns the single ID argument of an action.
+func oneID(args []string) (string, error) {
+	if len(args) != 1 {
+		return "", fmt.Errorf("%w: expected one user ID", errUsage)
+	}
+	return args[0], nil
+}
+
+// splitList splits a comma separated list, dropping empty values.
+func splitList(s string) []string {
+	var list []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
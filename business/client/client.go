@@ -0,0 +1,248 @@
+// Package client provides a typed client for the sales API. It reuses the
+// models of the service, retries failed requests and propagates the trace
+// context of the caller. Only the user endpoints exist in the API so far,
+// the product, sale and token endpoints will be added along with them.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mohammadhsn/ultimate-service/business/sys/validate"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// Config contains the settings of a client.
+type Config struct {
+	// BaseURL is the address of the service, like http://localhost:3000.
+	BaseURL string
+
+	// HTTPClient sends the requests. The default client propagates the
+	// trace context through the W3C traceparent header.
+	HTTPClient *http.Client
+
+	// AdminKey is sent as a bearer token in the Authorization header when
+	// set. The admin routes, like the user export, require it.
+	AdminKey string
+
+	// MaxRetries is how many times a request failing with a 5xx or 429
+	// response, or a network error, is retried.
+	MaxRetries int
+
+	// Backoff is the wait before the first retry, doubled for every other
+	// retry unless the service sends a Retry-After header.
+	Backoff time.Duration
+}
+
+// Client sends requests to the sales API.
+type Client struct {
+	baseURL    string
+	http       *http.Client
+	adminKey   string
+	maxRetries int
+	backoff    time.Duration
+}
+
+// New constructs a client for the service.
+func New(cfg Config) *Client {
+	hc := cfg.HTTPClient
+	if hc == nil {
+		hc = &http.Client{
+			Transport: otelhttp.NewTransport(http.DefaultTransport, otelhttp.WithPropagators(propagation.TraceContext{})),
+			Timeout:   30 * time.Second,
+		}
+	}
+
+	backoff := cfg.Backoff
+	if backoff <= 0 {
+		backoff = 100 * time.Millisecond
+	}
+
+	return &Client{
+		baseURL:    strings.TrimRight(cfg.BaseURL, "/"),
+		http:       hc,
+		adminKey:   cfg.AdminKey,
+		maxRetries: cfg.MaxRetries,
+		backoff:    backoff,
+	}
+}
+
+// maxBackoff caps the wait between two retries.
+const maxBackoff = 10 * time.Second
+
+// Error is the error returned when the service responds with an error
+// status code. It carries the validate.ErrorResponse sent by the service.
+type Error struct {
+	StatusCode int
+	validate.ErrorResponse
+}
+
+// Error implements the error interface.
+func (e *Error) Error() string {
+	if e.Fields != "" {
+		return fmt.Sprintf("%d: %s: %s", e.StatusCode, e.ErrorResponse.Error, e.Fields)
+	}
+	return fmt.Sprintf("%d: %s", e.StatusCode, e.ErrorResponse.Error)
+}
+
+// FieldErrors returns the field errors of a data validation error.
+func (e *Error) FieldErrors() validate.FieldErrors {
+	var fields validate.FieldErrors
+	if e.Fields != "" {
+		json.Unmarshal([]byte(e.Fields), &fields)
+	}
+	return fields
+}
+
+// StatusCode returns the status code of the error response the service
+// sent, or 0 if err does not come from one.
+func StatusCode(err error) int {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.StatusCode
+	}
+	return 0
+}
+
+// request describes a call to the service.
+type request struct {
+	method string
+	path   string
+	header http.Header
+	body   interface{}
+}
+
+// do sends the request, retrying it when it failed with a transient error,
+// and returns the successful response. The caller must close its body.
+func (c *Client) do(ctx context.Context, req request) (*http.Response, error) {
+	var body []byte
+	if req.body != nil {
+		b, err := json.Marshal(req.body)
+		if err != nil {
+			return nil, fmt.Errorf("encoding request: %w", err)
+		}
+		body = b
+	}
+
+	header := req.header.Clone()
+	if header == nil {
+		header = make(http.Header)
+	}
+	if header.Get("Accept") == "" {
+		header.Set("Accept", "application/json")
+	}
+	if body != nil {
+		header.Set("Content-Type", "application/json")
+	}
+	if c.adminKey != "" {
+		header.Set("Authorization", "Bearer "+c.adminKey)
+	}
+
+	// Every attempt of a POST carries the same key, so the service runs it
+	// at most once however many times it is retried.
+	if req.method == http.MethodPost && header.Get("Idempotency-Key") == "" {
+		header.Set("Idempotency-Key", uuid.NewString())
+	}
+
+	for attempt := 0; ; attempt++ {
+		r, err := http.NewRequestWithContext(ctx, req.method, c.baseURL+req.path, bytes.NewReader(body))
+		if err != nil {
+			return nil, fmt.Errorf("creating request: %w", err)
+		}
+		r.Header = header.Clone()
+
+		resp, err := c.http.Do(r)
+		if err == nil && resp.StatusCode < http.StatusBadRequest {
+			return resp, nil
+		}
+
+		var wait time.Duration
+		switch {
+		case err != nil:
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			err = fmt.Errorf("%s %s: %w", req.method, req.path, err)
+		default:
+			wait = retryAfter(resp)
+			err = decodeError(resp)
+		}
+
+		if attempt >= c.maxRetries || !retryable(err) {
+			return nil, err
+		}
+
+		if wait == 0 {
+			wait = c.wait(attempt)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+// retryable reports whether the request may succeed when sent again.
+func retryable(err error) bool {
+	code := StatusCode(err)
+	return code == 0 || code == http.StatusTooManyRequests || code >= http.StatusInternalServerError
+}
+
+// wait returns the exponential backoff with jitter before the retry.
+func (c *Client) wait(attempt int) time.Duration {
+	d := c.backoff << attempt
+	if d <= 0 || d > maxBackoff {
+		d = maxBackoff
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+// retryAfter returns the wait the service asked for in the Retry-After
+// header, capped to the longest backoff.
+func retryAfter(resp *http.Response) time.Duration {
+	secs, err := strconv.Atoi(resp.Header.Get("Retry-After"))
+	if err != nil || secs < 0 {
+		return 0
+	}
+	if d := time.Duration(secs) * time.Second; d < maxBackoff {
+		return d
+	}
+	return maxBackoff
+}
+
+// decodeError reads the error response and closes its body.
+func decodeError(resp *http.Response) error {
+	defer resp.Body.Close()
+
+	e := Error{StatusCode: resp.StatusCode}
+
+	b, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil || json.Unmarshal(b, &e.ErrorResponse) != nil || e.ErrorResponse.Error == "" {
+		e.ErrorResponse.Error = http.StatusText(resp.StatusCode)
+	}
+
+	return &e
+}
+
+// decode reads the JSON response into the value and closes its body.
+func decode(resp *http.Response, val interface{}) error {
+	defer resp.Body.Close()
+
+	if err := json.NewDecoder(resp.Body).Decode(val); err != nil {
+		return fmt.Errorf("decoding response: %w", err)
+	}
+	return nil
+}
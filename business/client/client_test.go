@@ -0,0 +1,234 @@
+package client_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mohammadhsn/ultimate-service/app/services/sales/handlers"
+	"github.com/mohammadhsn/ultimate-service/business/client"
+	"github.com/mohammadhsn/ultimate-service/business/data/store/user"
+	"github.com/mohammadhsn/ultimate-service/business/data/tests"
+	"go.uber.org/zap"
+)
+
+const (
+	success = "\u2713"
+	failed  = "\u2717"
+)
+
+func TestClientErrors(t *testing.T) {
+	api := httptest.NewServer(handlers.APIMux(handlers.APIMuxConfig{
+		Shutdown: make(chan os.Signal, 1),
+		Log:      zap.NewNop().Sugar(),
+	}))
+	t.Cleanup(api.Close)
+
+	c := client.New(client.Config{BaseURL: api.URL})
+
+	t.Log("Given the need to get typed errors from the API.")
+	{
+		t.Log("\tTest 0:\tWhen asking for a user with an invalid ID.")
+		{
+			_, err := c.QueryUserByID(context.Background(), "not-an-id")
+
+			var e *client.Error
+			if !errors.As(err, &e) {
+				t.Fatalf("\t%s\tTest 0:\tShould get a client error: %v.", failed, err)
+			}
+			t.Logf("\t%s\tTest 0:\tShould get a client error.", success)
+
+			if e.StatusCode != http.StatusBadRequest || !strings.Contains(e.ErrorResponse.Error, "ID is not in its proper form") {
+				t.Fatalf("\t%s\tTest 0:\tShould get the error response of the API: %v.", failed, e)
+			}
+			t.Logf("\t%s\tTest 0:\tShould get the error response of the API.", success)
+		}
+
+		t.Log("\tTest 1:\tWhen the API rejects the data.")
+		{
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(`{"error":"data validation error","fields":"[{\"field\":\"email\",\"error\":\"email must be a valid email address\"}]"}`))
+			}))
+			t.Cleanup(srv.Close)
+
+			_, err := client.New(client.Config{BaseURL: srv.URL}).CreateUser(context.Background(), user.NewUser{})
+
+			var e *client.Error
+			if !errors.As(err, &e) || len(e.FieldErrors()) != 1 || e.FieldErrors()[0].Field != "email" {
+				t.Fatalf("\t%s\tTest 1:\tShould get the field errors: %v.", failed, err)
+			}
+			t.Logf("\t%s\tTest 1:\tShould get the field errors.", success)
+		}
+
+		t.Log("\tTest 2:\tWhen the export fails half way.")
+		{
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/x-ndjson")
+				w.Write([]byte(`{"id":"1","name":"bill"}` + "\n" + `{"error":"Internal Server Error"}` + "\n"))
+			}))
+			t.Cleanup(srv.Close)
+
+			var names []string
+			err := client.New(client.Config{BaseURL: srv.URL}).ExportUsers(context.Background(), func(usr user.User) error {
+				names = append(names, usr.Name)
+				return nil
+			})
+
+			if client.StatusCode(err) != http.StatusInternalServerError {
+				t.Fatalf("\t%s\tTest 2:\tShould get an error: %v.", failed, err)
+			}
+			t.Logf("\t%s\tTest 2:\tShould get an error.", success)
+
+			if len(names) != 1 || names[0] != "bill" {
+				t.Fatalf("\t%s\tTest 2:\tShould get the users before the failure: %v.", failed, names)
+			}
+			t.Logf("\t%s\tTest 2:\tShould get the users before the failure.", success)
+		}
+	}
+}
+
+func TestClientRetries(t *testing.T) {
+	var mu sync.Mutex
+	var keys []string
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		keys = append(keys, r.Header.Get("Idempotency-Key"))
+		n := len(keys)
+		mu.Unlock()
+
+		switch n {
+		case 1:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case 2:
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+		default:
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(user.User{ID: "1", Name: "gopher"})
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	t.Log("Given the need to retry requests failing with transient errors.")
+	{
+		t.Log("\tTest 0:\tWhen the API fails twice before creating the user.")
+		{
+			c := client.New(client.Config{BaseURL: srv.URL, MaxRetries: 2, Backoff: time.Millisecond})
+
+			usr, err := c.CreateUser(context.Background(), user.NewUser{Name: "gopher"})
+			if err != nil || usr.Name != "gopher" {
+				t.Fatalf("\t%s\tTest 0:\tShould create the user: %v.", failed, err)
+			}
+			t.Logf("\t%s\tTest 0:\tShould create the user.", success)
+
+			if len(keys) != 3 || keys[0] == "" || keys[0] != keys[1] || keys[1] != keys[2] {
+				t.Fatalf("\t%s\tTest 0:\tShould send the same idempotency key every time: %v.", failed, keys)
+			}
+			t.Logf("\t%s\tTest 0:\tShould send the same idempotency key every time.", success)
+		}
+
+		t.Log("\tTest 1:\tWhen the retries are exhausted.")
+		{
+			mu.Lock()
+			keys = nil
+			mu.Unlock()
+
+			c := client.New(client.Config{BaseURL: srv.URL, MaxRetries: 1, Backoff: time.Millisecond})
+
+			_, err := c.CreateUser(context.Background(), user.NewUser{Name: "gopher"})
+			if client.StatusCode(err) != http.StatusTooManyRequests {
+				t.Fatalf("\t%s\tTest 1:\tShould get the last error: %v.", failed, err)
+			}
+			t.Logf("\t%s\tTest 1:\tShould get the last error.", success)
+		}
+	}
+}
+
+func TestClientUsers(t *testing.T) {
+	log, db := tests.NewUnit(t)
+
+	api := httptest.NewServer(handlers.APIMux(handlers.APIMuxConfig{
+		Shutdown:       make(chan os.Signal, 1),
+		Log:            log,
+		DB:             db,
+		AdminKey:       "s3cret",
+		IdempotencyTTL: time.Hour,
+	}))
+	t.Cleanup(api.Close)
+
+	cl := client.New(client.Config{BaseURL: api.URL, AdminKey: "s3cret"})
+	ctx := context.Background()
+
+	t.Log("Given the need to work with users through the client.")
+	{
+		t.Log("\tTest 0:\tWhen reading a seeded user.")
+		{
+			usr, err := cl.QueryUserByID(ctx, tests.SeedAdminID)
+			if err != nil || usr.Email != "admin@example.com" {
+				t.Fatalf("\t%s\tTest 0:\tShould get the user: %v.", failed, err)
+			}
+			t.Logf("\t%s\tTest 0:\tShould get the user.", success)
+		}
+
+		t.Log("\tTest 1:\tWhen creating, updating and deleting a user.")
+		{
+			usr, err := cl.CreateUser(ctx, user.NewUser{
+				Name:            "Bill Kennedy",
+				Email:           "bill@ardanlabs.com",
+				Roles:           []string{"ADMIN"},
+				Password:        "gophers",
+				PasswordConfirm: "gophers",
+			})
+			if err != nil || usr.ID == "" || usr.Version != 1 {
+				t.Fatalf("\t%s\tTest 1:\tShould create the user: %v.", failed, err)
+			}
+			t.Logf("\t%s\tTest 1:\tShould create the user.", success)
+
+			name := "Jacob Kennedy"
+			upd, err := cl.UpdateUser(ctx, usr.ID, user.UpdateUser{Name: &name}, usr.Version)
+			if err != nil || upd.Name != name || upd.Version != 2 {
+				t.Fatalf("\t%s\tTest 1:\tShould update the user: %v.", failed, err)
+			}
+			t.Logf("\t%s\tTest 1:\tShould update the user.", success)
+
+			if _, err := cl.UpdateUser(ctx, usr.ID, user.UpdateUser{Name: &name}, usr.Version); client.StatusCode(err) != http.StatusPreconditionFailed {
+				t.Fatalf("\t%s\tTest 1:\tShould not update an outdated version: %v.", failed, err)
+			}
+			t.Logf("\t%s\tTest 1:\tShould not update an outdated version.", success)
+
+			var ids []string
+			if err := cl.ExportUsers(ctx, func(u user.User) error {
+				ids = append(ids, u.ID)
+				return nil
+			}); err != nil || len(ids) != 3 {
+				t.Fatalf("\t%s\tTest 1:\tShould export every user: got %d: %v.", failed, len(ids), err)
+			}
+			t.Logf("\t%s\tTest 1:\tShould export every user.", success)
+
+			anon := client.New(client.Config{BaseURL: api.URL})
+			if err := anon.ExportUsers(ctx, func(user.User) error { return nil }); client.StatusCode(err) != http.StatusUnauthorized {
+				t.Fatalf("\t%s\tTest 1:\tShould not export users without the admin key: %v.", failed, err)
+			}
+			t.Logf("\t%s\tTest 1:\tShould not export users without the admin key.", success)
+
+			if err := cl.DeleteUser(ctx, usr.ID); err != nil {
+				t.Fatalf("\t%s\tTest 1:\tShould delete the user: %v.", failed, err)
+			}
+			t.Logf("\t%s\tTest 1:\tShould delete the user.", success)
+
+			if _, err := cl.QueryUserByID(ctx, usr.ID); client.StatusCode(err) != http.StatusNotFound {
+				t.Fatalf("\t%s\tTest 1:\tShould not find the deleted user: %v.", failed, err)
+			}
+			t.Logf("\t%s\tTest 1:\tShould not find the deleted user.", success)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/mohammadhsn/ultimate-service/business/data/store/user"
+	"github.com/mohammadhsn/ultimate-service/business/sys/validate"
+	"github.com/mohammadhsn/ultimate-service/foundation/web"
+)
+
+// CreateUser adds a new user.
+func (c *Client) CreateUser(ctx context.Context, nu user.NewUser) (user.User, error) {
+	resp, err := c.do(ctx, request{
+		method: http.MethodPost,
+		path:   "/v1/users",
+		body:   nu,
+	})
+	if err != nil {
+		return user.User{}, err
+	}
+
+	var usr user.User
+	if err := decode(resp, &usr); err != nil {
+		return user.User{}, err
+	}
+	return usr, nil
+}
+
+// QueryUserByID returns the user with the ID.
+func (c *Client) QueryUserByID(ctx context.Context, id string) (user.User, error) {
+	resp, err := c.do(ctx, request{
+		method: http.MethodGet,
+		path:   "/v1/users/" + url.PathEscape(id),
+	})
+	if err != nil {
+		return user.User{}, err
+	}
+
+	var usr user.User
+	if err := decode(resp, &usr); err != nil {
+		return user.User{}, err
+	}
+	return usr, nil
+}
+
+// UpdateUser modifies the user if it is still at the version, which is the
+// Version of the user the changes are based on. The service responds with
+// 412 when the user was changed in the meantime.
+func (c *Client) UpdateUser(ctx context.Context, id string, uu user.UpdateUser, version int) (user.User, error) {
+	resp, err := c.do(ctx, request{
+		method: http.MethodPut,
+		path:   "/v1/users/" + url.PathEscape(id),
+		header: http.Header{"If-Match": {web.ETag(strconv.Itoa(version))}},
+		body:   uu,
+	})
+	if err != nil {
+		return user.User{}, err
+	}
+
+	var usr user.User
+	if err := decode(resp, &usr); err != nil {
+		return user.User{}, err
+	}
+	return usr, nil
+}
+
+// DeleteUser removes the user with the ID.
+func (c *Client) DeleteUser(ctx context.Context, id string) error {
+	resp, err := c.do(ctx, request{
+		method: http.MethodDelete,
+		path:   "/v1/users/" + url.PathEscape(id),
+	})
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	return nil
+}
+
+// ExportUsers calls fn for every user, as the service streams them. It stops
+// at the first error fn returns. When the service fails half way, fn was
+// only called for some of the users and an *Error is returned.
+func (c *Client) ExportUsers(ctx context.Context, fn func(user.User) error) error {
+	resp, err := c.do(ctx, request{
+		method: http.MethodGet,
+		path:   "/v1/users/export",
+		header: http.Header{"Accept": {"application/x-ndjson"}},
+	})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1<<20)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		// The service ends the stream with an error line when it fails half
+		// way, the users before it are not all of them.
+		var line struct {
+			user.User
+			Error string `json:"error"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			return fmt.Errorf("decoding user: %w", err)
+		}
+		if line.Error != "" {
+			return &Error{
+				StatusCode:    http.StatusInternalServerError,
+				ErrorResponse: validate.ErrorResponse{Error: line.Error},
+			}
+		}
+		if err := fn(line.User); err != nil {
+			return err
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("reading users: %w", err)
+	}
+	return nil
+}
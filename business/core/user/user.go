@@ -10,20 +10,24 @@ import (
 
 	"github.com/jmoiron/sqlx"
 	"github.com/mohammadhsn/ultimate-service/business/data/store/user"
+	"github.com/mohammadhsn/ultimate-service/foundation/cache"
 	"go.uber.org/zap"
 )
 
 // Core manages the set of APIs for user access.
 type Core struct {
-	log  *zap.SugaredLogger
-	user user.Store
+	log   *zap.SugaredLogger
+	user  user.Store
+	cache *cache.Cache
 }
 
-// NewCore constructs a core for user api access.
-func NewCore(log *zap.SugaredLogger, db *sqlx.DB) Core {
+// NewCore constructs a core for user api access. Users looked up by ID are
+// kept in the cache, which may be nil to disable caching.
+func NewCore(log *zap.SugaredLogger, db *sqlx.DB, cache *cache.Cache) Core {
 	return Core{
-		log:  log,
-		user: user.NewStore(log, db),
+		log:   log,
+		user:  user.NewStore(log, db),
+		cache: cache,
 	}
 }
 
@@ -42,17 +46,22 @@ func (c Core) Create(ctx context.Context, nu user.NewUser, now time.Time) (user.
 	return usr, nil
 }
 
-func (c Core) Update(ctx context.Context, userId string, uu user.UpdateUser, now time.Time) error {
-
-	if err := c.user.Update(ctx, userId, uu, now); err != nil {
-		return fmt.Errorf("update %w", err)
+// Update modifies the user if it is still at the given version and returns
+// it with its new version.
+func (c Core) Update(ctx context.Context, userId string, uu user.UpdateUser, version int, now time.Time) (user.User, error) {
+	usr, err := c.user.Update(ctx, userId, uu, version, now)
+	c.cache.Delete(userId)
+	if err != nil {
+		return user.User{}, fmt.Errorf("update: %w", err)
 	}
 
-	return nil
+	return usr, nil
 }
 
 func (c Core) Delete(ctx context.Context, userId string) error {
-	if err := c.user.Delete(ctx, userId); err != nil {
+	err := c.user.Delete(ctx, userId)
+	c.cache.Delete(userId)
+	if err != nil {
 		return fmt.Errorf("delete: %w", err)
 	}
 
@@ -67,14 +76,55 @@ func (c Core) Query(ctx context.Context, pageNumber int, rowsPerPage int) ([]use
 	return users, nil
 }
 
+// QueryAll calls fn for every user without loading them all in memory.
+func (c Core) QueryAll(ctx context.Context, fn func(user.User) error) error {
+	if err := c.user.QueryAll(ctx, fn); err != nil {
+		return fmt.Errorf("query all: %w", err)
+	}
+	return nil
+}
+
+// QueryById gets the specified user, from the cache when it holds it. The
+// cache hands out copies, callers may change the user they get.
 func (c Core) QueryById(ctx context.Context, userId string) (user.User, error) {
+	if v, ok := c.cache.Get(userId); ok {
+		return clone(v.(user.User)), nil
+	}
+
+	// A user loaded while it is updated or deleted is not cached, the write
+	// may have removed the cached copy before this one is stored.
+	gen := c.cache.Generation()
+
 	usr, err := c.user.QueryById(ctx, userId)
 	if err != nil {
 		return user.User{}, fmt.Errorf("query: %w", err)
 	}
+
+	c.cache.SetIfGeneration(userId, clone(usr), gen)
+
+	return usr, nil
+}
+
+// QueryByIdFresh gets the specified user from the database, never from the
+// cache. Writes checking the version of the user rely on it, so they never
+// compare against an outdated copy.
+func (c Core) QueryByIdFresh(ctx context.Context, userId string) (user.User, error) {
+	usr, err := c.user.QueryById(ctx, userId)
+	if err != nil {
+		return user.User{}, fmt.Errorf("query: %w", err)
+	}
+
 	return usr, nil
 }
 
+// clone returns a copy of the user that shares no memory with it.
+func clone(usr user.User) user.User {
+	if usr.Roles != nil {
+		usr.Roles = append([]string(nil), usr.Roles...)
+	}
+	return usr
+}
+
 func (c Core) Authenticate(ctx context.Context, now time.Time, email, password string) error {
 	if err := c.user.Authenticate(ctx, now, email, password); err != nil {
 		return fmt.Errorf("authenticate: %w", err)
@@ -1,3 +1,4 @@
+DELETE FROM idempotency_keys;
 DELETE FROM sales;
 DELETE FROM products;
 DELETE FROM users;
@@ -41,3 +41,23 @@ CREATE TABLE sales(
     FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE,
     FOREIGN KEY (product_id) REFERENCES products(product_id) ON DELETE CASCADE
 );
+
+-- Version: 1.4
+-- Description: Create table idempotency_keys
+CREATE TABLE idempotency_keys (
+    idempotency_key  TEXT,
+    fingerprint      TEXT,
+    status_code      INT,
+    content_type     TEXT,
+    content_encoding TEXT,
+    body             BYTEA,
+    date_created     TIMESTAMP,
+    date_expires     TIMESTAMP,
+
+    PRIMARY KEY (idempotency_key)
+);
+
+-- Version: 1.5
+-- Description: Add version columns for optimistic concurrency
+ALTER TABLE users ADD COLUMN version INT NOT NULL DEFAULT 1;
+ALTER TABLE products ADD COLUMN version INT NOT NULL DEFAULT 1;
@@ -0,0 +1,157 @@
+package idempotency
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/mohammadhsn/ultimate-service/business/sys/database"
+	"go.uber.org/zap"
+)
+
+// Store manages the set of APIs for idempotency key access.
+type Store struct {
+	log *zap.SugaredLogger
+	db  *sqlx.DB
+}
+
+// NewStore constructs an idempotency key store for api access.
+func NewStore(log *zap.SugaredLogger, db *sqlx.DB) Store {
+	return Store{
+		log: log,
+		db:  db,
+	}
+}
+
+// Reserve claims the key for a request about to be processed. When the key
+// is already in use, the stored key is returned and reserved is false. An
+// expired key is claimed again as if it was new, so is a key released while
+// it was being reserved. If it keeps being released, the error wraps
+// database.ErrNotFound.
+func (s Store) Reserve(ctx context.Context, key Key) (stored Key, reserved bool, err error) {
+	key.StatusCode = 0
+	key.ContentType = ""
+	key.ContentEncoding = ""
+	key.Body = []byte{}
+
+	const q = `
+	INSERT INTO idempotency_keys
+		(idempotency_key, fingerprint, status_code, content_type, content_encoding, body, date_created, date_expires)
+	VALUES
+		(:idempotency_key, :fingerprint, :status_code, :content_type, :content_encoding, :body, :date_created, :date_expires)
+	ON CONFLICT (idempotency_key) DO UPDATE SET
+		"fingerprint" = EXCLUDED.fingerprint,
+		"status_code" = EXCLUDED.status_code,
+		"content_type" = EXCLUDED.content_type,
+		"content_encoding" = EXCLUDED.content_encoding,
+		"body" = EXCLUDED.body,
+		"date_created" = EXCLUDED.date_created,
+		"date_expires" = EXCLUDED.date_expires
+	WHERE
+		idempotency_keys.date_expires < EXCLUDED.date_created
+	RETURNING idempotency_key`
+
+	for attempt := 1; ; attempt++ {
+		var dest struct {
+			Key string `db:"idempotency_key"`
+		}
+		err = database.NamedQueryStruct(ctx, s.log, s.db, q, key, &dest)
+		switch {
+		case err == nil:
+			return key, true, nil
+		case !errors.Is(err, database.ErrNotFound):
+			return Key{}, false, fmt.Errorf("reserving key[%s]: %w", key.Key, err)
+		}
+
+		// The key may be released between the insert and the select.
+		stored, err = s.QueryByKey(ctx, key.Key)
+		switch {
+		case err == nil:
+			return stored, false, nil
+		case !errors.Is(err, database.ErrNotFound) || attempt == maxReserveAttempts:
+			return Key{}, false, fmt.Errorf("reserving key[%s]: %w", key.Key, err)
+		}
+	}
+}
+
+// maxReserveAttempts is how many times Reserve tries to claim a key that is
+// released while it is reserved.
+const maxReserveAttempts = 3
+
+// Complete records the response of the request made with the key.
+func (s Store) Complete(ctx context.Context, key Key) error {
+	const q = `
+	UPDATE
+		idempotency_keys
+	SET
+		"status_code" = :status_code,
+		"content_type" = :content_type,
+		"content_encoding" = :content_encoding,
+		"body" = :body
+	WHERE
+		idempotency_key = :idempotency_key`
+
+	if err := database.NamedExecContext(ctx, s.log, s.db, q, key); err != nil {
+		return fmt.Errorf("completing key[%s]: %w", key.Key, err)
+	}
+
+	return nil
+}
+
+// Release deletes a key whose request failed, so the client can retry it.
+// Keys with a recorded response are kept.
+func (s Store) Release(ctx context.Context, key string) error {
+	data := struct {
+		Key string `db:"idempotency_key"`
+	}{
+		Key: key,
+	}
+
+	const q = `DELETE FROM idempotency_keys WHERE idempotency_key = :idempotency_key AND status_code = 0`
+
+	if err := database.NamedExecContext(ctx, s.log, s.db, q, data); err != nil {
+		return fmt.Errorf("releasing key[%s]: %w", key, err)
+	}
+
+	return nil
+}
+
+// DeleteExpired deletes the keys that expired before now.
+func (s Store) DeleteExpired(ctx context.Context, now time.Time) error {
+	data := struct {
+		Now time.Time `db:"now"`
+	}{
+		Now: now,
+	}
+
+	const q = `DELETE FROM idempotency_keys WHERE date_expires < :now`
+
+	if err := database.NamedExecContext(ctx, s.log, s.db, q, data); err != nil {
+		return fmt.Errorf("deleting expired keys: %w", err)
+	}
+
+	return nil
+}
+
+// QueryByKey gets the specified key from the database.
+func (s Store) QueryByKey(ctx context.Context, key string) (Key, error) {
+	data := struct {
+		Key string `db:"idempotency_key"`
+	}{
+		Key: key,
+	}
+
+	const q = `SELECT * FROM idempotency_keys WHERE idempotency_key = :idempotency_key`
+
+	var k Key
+	if err := database.NamedQueryStruct(ctx, s.log, s.db, q, data, &k); err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			return Key{}, database.ErrNotFound
+		}
+		return Key{}, fmt.Errorf("selecting key[%s]: %w", key, err)
+	}
+
+	return k, nil
+}
@@ -0,0 +1,19 @@
+// Package idempotency contains the storage of idempotency keys and the
+// responses recorded for them.
+package idempotency
+
+import "time"
+
+// Key represents an idempotency key sent by a client together with the
+// response of the request first made with it. A zero status code means the
+// request is still being processed.
+type Key struct {
+	Key             string    `db:"idempotency_key"`
+	Fingerprint     string    `db:"fingerprint"`
+	StatusCode      int       `db:"status_code"`
+	ContentType     string    `db:"content_type"`
+	ContentEncoding string    `db:"content_encoding"`
+	Body            []byte    `db:"body"`
+	DateCreated     time.Time `db:"date_created"`
+	DateExpires     time.Time `db:"date_expires"`
+}
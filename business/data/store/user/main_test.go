@@ -0,0 +1,12 @@
+package user_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/mohammadhsn/ultimate-service/business/data/tests"
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(tests.Main(m))
+}
@@ -13,15 +13,16 @@ type User struct {
 	Name         string         `db:"name" json:"name"`
 	Email        string         `db:"email" json:"email"`
 	Roles        pq.StringArray `db:"roles" json:"roles"`
-	PasswordHash []byte         `db:"password_hash" json:"_"`
+	PasswordHash []byte         `db:"password_hash" json:"-"`
 	DateCreated  time.Time      `db:"date_created" json:"dateCreated"`
 	DateUpdated  time.Time      `db:"date_updated" json:"dateUpdated"`
+	Version      int            `db:"version" json:"version"`
 }
 
 // NewUser contains information needed to create a new User.
 type NewUser struct {
-	Name            string   `db:"name" validate:"required"`
-	Email           string   `db:"email" validate:"required,email"`
+	Name            string   `json:"name" validate:"required"`
+	Email           string   `json:"email" validate:"required,email"`
 	Roles           []string `json:"roles" validate:"required"`
 	Password        string   `json:"password" validate:"required"`
 	PasswordConfirm string   `json:"password_confirm" validate:"eqfield=Password"`
@@ -2,6 +2,7 @@ package user
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
@@ -46,13 +47,14 @@ func (s Store) Create(ctx context.Context, nu NewUser, now time.Time) (User, err
 		Roles:        nu.Roles,
 		DateCreated:  now,
 		DateUpdated:  now,
+		Version:      1,
 	}
 
 	const q = `
 	INSERT INTO users
-		(user_id, name, email, password_hash, roles, date_created, date_updated)
+		(user_id, name, email, password_hash, roles, date_created, date_updated, version)
 	VALUES
-	    (:user_id, :name, :email, :password_hash, :roles, :date_created, :date_updated)`
+	    (:user_id, :name, :email, :password_hash, :roles, :date_created, :date_updated, :version)`
 
 	if err := database.NamedExecContext(ctx, s.log, s.db, q, usr); err != nil {
 		return User{}, fmt.Errorf("inserting user: %w", err)
@@ -81,20 +83,25 @@ func (s Store) Delete(ctx context.Context, userId string) error {
 	return nil
 }
 
-// Update replaces a user document in the database.
-func (s Store) Update(ctx context.Context, userId string, uu UpdateUser, now time.Time) error {
+// Update replaces a user document in the database. The version must be
+// the version of the user the changes are based on, ErrVersionConflict is
+// returned when the user was changed in the meantime.
+func (s Store) Update(ctx context.Context, userId string, uu UpdateUser, version int, now time.Time) (User, error) {
 	if err := validate.CheckId(userId); err != nil {
-		return database.ErrInvalidID
+		return User{}, database.ErrInvalidID
 	}
 
 	if err := validate.Check(uu); err != nil {
-		return fmt.Errorf("validating data: %w", err)
+		return User{}, fmt.Errorf("validating data: %w", err)
 	}
 
 	usr, err := s.QueryById(ctx, userId)
 
 	if err != nil {
-		return fmt.Errorf("updating user userId[%s]: %w", userId, err)
+		return User{}, fmt.Errorf("updating user userId[%s]: %w", userId, err)
+	}
+	if usr.Version != version {
+		return User{}, database.ErrVersionConflict
 	}
 	if uu.Name != nil {
 		usr.Name = *uu.Name
@@ -108,12 +115,14 @@ func (s Store) Update(ctx context.Context, userId string, uu UpdateUser, now tim
 	if uu.Password != nil {
 		pw, err := bcrypt.GenerateFromPassword([]byte(*uu.Password), bcrypt.DefaultCost)
 		if err != nil {
-			return fmt.Errorf("generating password hash: %w", err)
+			return User{}, fmt.Errorf("generating password hash: %w", err)
 		}
 		usr.PasswordHash = pw
 	}
 	usr.DateUpdated = now
 
+	// The version in the WHERE clause makes sure nobody updated the user
+	// since it was read, no row is returned otherwise.
 	const q string = `
 	UPDATE
 		users
@@ -122,15 +131,24 @@ func (s Store) Update(ctx context.Context, userId string, uu UpdateUser, now tim
 	    "email" = :email,
 	    "roles" = :roles,
 	    "password_hash" = :password_hash,
-	    "date_updated" = :date_updated
+	    "date_updated" = :date_updated,
+	    "version" = version + 1
 	WHERE
-		user_id = :user_id`
+		user_id = :user_id AND version = :version
+	RETURNING version`
 
-	if err := database.NamedExecContext(ctx, s.log, s.db, q, usr); err != nil {
-		return fmt.Errorf("updating userId[%s]: %w", userId, err)
+	var dest struct {
+		Version int `db:"version"`
+	}
+	if err := database.NamedQueryStruct(ctx, s.log, s.db, q, usr, &dest); err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			return User{}, database.ErrVersionConflict
+		}
+		return User{}, fmt.Errorf("updating userId[%s]: %w", userId, err)
 	}
+	usr.Version = dest.Version
 
-	return nil
+	return usr, nil
 }
 
 func (s Store) Query(ctx context.Context, pageNumber int, rowsPerPage int) ([]User, error) {
@@ -155,6 +173,35 @@ func (s Store) Query(ctx context.Context, pageNumber int, rowsPerPage int) ([]Us
 	return users, nil
 }
 
+// QueryAll calls fn for every user, ordered by user ID. The users are read
+// from the database one row at a time and it stops at the first error fn
+// returns.
+func (s Store) QueryAll(ctx context.Context, fn func(User) error) error {
+	const q string = `SELECT * FROM users ORDER BY user_id`
+
+	it, err := database.NamedQueryIterator(ctx, s.log, s.db, q, struct{}{})
+	if err != nil {
+		return fmt.Errorf("selecting users: %w", err)
+	}
+	defer it.Close()
+
+	for it.Next() {
+		var usr User
+		if err := it.Scan(&usr); err != nil {
+			return fmt.Errorf("scanning user: %w", err)
+		}
+		if err := fn(usr); err != nil {
+			return err
+		}
+	}
+
+	if err := it.Err(); err != nil {
+		return fmt.Errorf("iterating users: %w", err)
+	}
+
+	return nil
+}
+
 // QueryById gets the specified user from the database.
 func (s Store) QueryById(ctx context.Context, userId string) (User, error) {
 	if err := validate.CheckId(userId); err != nil {
@@ -174,6 +221,7 @@ func (s Store) QueryById(ctx context.Context, userId string) (User, error) {
 		if err == database.ErrNotFound {
 			return User{}, database.ErrNotFound
 		}
+		return User{}, fmt.Errorf("selecting userId[%s]: %w", userId, err)
 	}
 
 	return usr, nil
@@ -12,15 +12,8 @@ import (
 	"github.com/mohammadhsn/ultimate-service/business/sys/database"
 )
 
-var dbc = tests.DBContainer{
-	Image: "postgres:14.5",
-	Port:  "5432",
-	Args:  []string{"-e", "POSTGRES_PASSWORD=postgres"},
-}
-
 func TestUser(t *testing.T) {
-	log, db, teardown := tests.NewUnit(t, dbc)
-	t.Cleanup(teardown)
+	log, db := tests.NewUnit(t)
 
 	store := user.NewStore(log, db)
 
@@ -63,11 +56,22 @@ func TestUser(t *testing.T) {
 			Email: tests.StringPointer("foo@bar.com"),
 		}
 
-		if err := store.Update(ctx, usr.ID, upd, now); err != nil {
+		updated, err := store.Update(ctx, usr.ID, upd, usr.Version, now)
+		if err != nil {
 			t.Fatalf("\t%s\tTest %d:\tShould be able to update user by ID: %s.", tests.Failed, testId, err)
 		}
 		t.Logf("\t%s\tTest %d:\tShould be able to update user.", tests.Success, testId)
 
+		if updated.Version != usr.Version+1 {
+			t.Fatalf("\t%s\tTest %d:\tShould get the next version: got %d, exp %d.", tests.Failed, testId, updated.Version, usr.Version+1)
+		}
+		t.Logf("\t%s\tTest %d:\tShould get the next version.", tests.Success, testId)
+
+		if _, err := store.Update(ctx, usr.ID, upd, usr.Version, now); !errors.Is(err, database.ErrVersionConflict) {
+			t.Fatalf("\t%s\tTest %d:\tShould NOT be able to update a stale version: %s.", tests.Failed, testId, err)
+		}
+		t.Logf("\t%s\tTest %d:\tShould NOT be able to update a stale version.", tests.Success, testId)
+
 		saved, err = store.QueryByEmail(ctx, *upd.Email)
 		if err != nil {
 			t.Fatalf("\t%s\tTest %d:\tShould be able to retrieve user by email: %s.", tests.Failed, testId, err)
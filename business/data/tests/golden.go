@@ -0,0 +1,135 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// update makes Golden write the golden files instead of comparing with them.
+var update = flag.Bool("update", false, "update the golden files of snapshot tests")
+
+// volatileFields are the JSON fields whose values change on every run.
+var volatileFields = []string{"dateCreated", "dateUpdated", "traceID", "traceId"}
+
+// uuidRE matches the UUIDs generated for new records.
+var uuidRE = regexp.MustCompile(`[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}`)
+
+// Golden compares the JSON document with the testdata/<name>.golden file of
+// the package and fails the test on any difference. UUIDs, timestamps,
+// trace IDs and the extra volatile fields are replaced by placeholders first.
+// Run the tests with -update to write the files instead.
+func Golden(t *testing.T, name string, doc []byte, volatile ...string) {
+	t.Helper()
+
+	got, err := normalize(doc, append(volatile, volatileFields...))
+	if err != nil {
+		t.Fatalf("\t%s\tShould be able to normalize the %s snapshot: %s", Failed, name, err)
+	}
+
+	path := filepath.Join("testdata", name+".golden")
+
+	if *update {
+		if err := os.MkdirAll("testdata", 0755); err != nil {
+			t.Fatalf("\t%s\tShould be able to create the testdata directory: %s", Failed, err)
+		}
+		if err := os.WriteFile(path, got, 0644); err != nil {
+			t.Fatalf("\t%s\tShould be able to update %s: %s", Failed, path, err)
+		}
+		t.Logf("\t%s\tUpdated %s.", Success, path)
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("\t%s\tShould be able to read %s, run the tests with -update to create it: %s", Failed, path, err)
+	}
+
+	if diff := cmp.Diff(string(want), string(got)); diff != "" {
+		t.Fatalf("\t%s\tShould match the %s snapshot, run the tests with -update if the change is intended. Diff (-want +got):\n%s", Failed, name, diff)
+	}
+	t.Logf("\t%s\tShould match the %s snapshot.", Success, name)
+}
+
+// Golden compares the status code and JSON body of the response with the
+// testdata/<name>.golden file. See the Golden function.
+func (r *Response) Golden(name string, volatile ...string) *Response {
+	r.t.Helper()
+
+	var body interface{}
+	if len(bytes.TrimSpace(r.Body)) > 0 {
+		r.JSON(&body)
+	}
+
+	doc, err := json.Marshal(struct {
+		StatusCode int         `json:"statusCode"`
+		Body       interface{} `json:"body"`
+	}{
+		StatusCode: r.Result.StatusCode,
+		Body:       body,
+	})
+	if err != nil {
+		r.t.Fatalf("\t%s\t%s: Should be able to encode the snapshot: %s", Failed, r.name, err)
+	}
+
+	Golden(r.t, name, doc, volatile...)
+
+	return r
+}
+
+// normalize replaces the volatile values of the JSON document and indents
+// it, with the object keys sorted.
+func normalize(doc []byte, volatile []string) ([]byte, error) {
+	var v interface{}
+	if err := json.Unmarshal(doc, &v); err != nil {
+		return nil, err
+	}
+
+	fields := make(map[string]bool)
+	for _, f := range volatile {
+		fields[f] = true
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "    ")
+	if err := enc.Encode(replace(v, fields)); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// replace walks the decoded JSON value, replacing the values of the volatile
+// fields and the UUIDs in strings by placeholders.
+func replace(v interface{}, fields map[string]bool) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, val := range v {
+			if fields[k] {
+				v[k] = "<" + k + ">"
+				continue
+			}
+			v[k] = replace(val, fields)
+		}
+		return v
+
+	case []interface{}:
+		for i := range v {
+			v[i] = replace(v[i], fields)
+		}
+		return v
+
+	case string:
+		return uuidRE.ReplaceAllString(v, "<uuid>")
+	}
+
+	return v
+}
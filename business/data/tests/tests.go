@@ -5,8 +5,8 @@ import (
 	"bytes"
 	"context"
 	"fmt"
-	"io"
-	"os"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -23,74 +23,236 @@ const (
 	Failed  = "\u2717"
 )
 
-// DBContainer provides configuration for a container to run.
+// templateDB is the database holding the migrated schema and the seed data
+// every test database is cloned from.
+const templateDB = "sales_template"
+
+// startTimeout is how long the database container has to become ready.
+const startTimeout = 30 * time.Second
+
+// DBContainer provides configuration for a container to run. The container
+// is ready once HealthCmd succeeds inside it.
 type DBContainer struct {
-	Image string
-	Port  string
-	Args  []string
+	Image     string
+	Port      string
+	Args      []string
+	HealthCmd string
 }
 
-// NewUnit creates a test database inside a Docker container. it creates the
-// required table structure but the database os otherwise empty. It returns
-// the database to use as well as a function to call at the end of the test.
-func NewUnit(t *testing.T, dbc DBContainer) (*zap.SugaredLogger, *sqlx.DB, func()) {
-	r, w, _ := os.Pipe()
-	old := os.Stdout
-	os.Stdout = w
+// Postgres is the database container the tests run against.
+var Postgres = DBContainer{
+	Image:     "postgres:14.5",
+	Port:      "5432",
+	Args:      []string{"-e", "POSTGRES_PASSWORD=postgres"},
+	HealthCmd: "pg_isready -U postgres",
+}
 
-	c := docker.StartContainer(t, dbc.Image, dbc.Port, dbc.Args...)
+// container is the database container shared by the tests of the package
+// calling Main.
+var container *docker.Container
 
-	db, err := database.Open(database.Config{
-		User:       "postgres",
-		Password:   "postgres",
-		Host:       c.Host,
-		Name:       "postgres",
-		DisableTLS: true,
-	})
+// Main starts the Postgres container, runs the tests of the package and
+// stops the container, returning the exit code of the tests. It is meant to
+// be called from TestMain:
+//
+//	func TestMain(m *testing.M) {
+//		os.Exit(tests.Main(m))
+//	}
+//
+// When the container can not start the tests still run, and the ones calling
+// NewUnit fail.
+func Main(m *testing.M) int {
+	var err error
+	container, err = startDB(Postgres)
+	if err != nil {
+		fmt.Println(err)
+	}
+
+	code := m.Run()
+
+	if err := stopDB(container); err != nil {
+		fmt.Println(err)
+	}
+
+	return code
+}
+
+// startDB starts a database container to be shared by all the tests of a
+// package and prepares the template database. Containers left over by
+// crashed test runs are removed first.
+func startDB(dbc DBContainer) (*docker.Container, error) {
+	if err := docker.RemoveStale(); err != nil {
+		return nil, err
+	}
 
+	c, err := docker.Start(docker.Config{
+		Image:     dbc.Image,
+		Port:      dbc.Port,
+		Args:      dbc.Args,
+		HealthCmd: dbc.HealthCmd,
+		Timeout:   startTimeout,
+	})
 	if err != nil {
-		t.Fatalf("openning database connection: %c", err)
+		return nil, err
 	}
 
-	t.Log("waiting for database to be ready ...")
+	if err := createTemplate(c); err != nil {
+		logs := docker.DumpContainerLogs(c.Id)
+		docker.StopContainer(c.Id)
+		return nil, fmt.Errorf("%w\nLogs for %s\n%s", err, c.Id, logs)
+	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	return c, nil
+}
+
+// stopDB stops and removes the database container.
+func stopDB(c *docker.Container) error {
+	if c == nil {
+		return nil
+	}
+	return docker.StopContainer(c.Id)
+}
+
+// createTemplate waits for the database to be ready, then creates the
+// template database, migrates it and seeds it.
+func createTemplate(c *docker.Container) error {
+	ctx, cancel := context.WithTimeout(context.Background(), startTimeout)
 	defer cancel()
 
+	admin, err := open(c, "postgres")
+	if err != nil {
+		return err
+	}
+	defer admin.Close()
+
+	if err := database.StatusCheck(ctx, admin); err != nil {
+		return fmt.Errorf("waiting for database: %w", err)
+	}
+
+	if _, err := admin.ExecContext(ctx, "CREATE DATABASE "+templateDB); err != nil {
+		return fmt.Errorf("creating template database: %w", err)
+	}
+
+	db, err := open(c, templateDB)
+	if err != nil {
+		return err
+	}
+
+	// The template can only be cloned once nobody is connected to it.
+	defer db.Close()
+
 	if err := schema.Migrate(ctx, db); err != nil {
-		docker.DumpContainerLogs(t, c.Id)
-		docker.StopContainer(t, c.Id)
-		t.Fatalf("Migrating error: %s", err)
+		return fmt.Errorf("migrating template database: %w", err)
 	}
 
 	if err := schema.Seed(ctx, db); err != nil {
-		docker.DumpContainerLogs(t, c.Id)
-		docker.StopContainer(t, c.Id)
-		t.Fatalf("seeding error: %s", err)
+		return fmt.Errorf("seeding template database: %w", err)
 	}
 
-	log, err := logger.New("TEST")
+	return nil
+}
+
+// dbCount numbers the databases created for tests.
+var dbCount int32
+
+// NewUnit creates a database for the test, cloned from the template database
+// of the container started by Main, so every test works on its own migrated
+// and seeded database. The database is dropped once the test ends and the
+// logs written during the test are shown if it failed.
+func NewUnit(t *testing.T) (*zap.SugaredLogger, *sqlx.DB) {
+	t.Helper()
+
+	c := container
+	if c == nil {
+		t.Fatalf("the database container is not running, see the TestMain output")
+	}
+
+	name := fmt.Sprintf("test_%d", atomic.AddInt32(&dbCount, 1))
+
+	ctx, cancel := context.WithTimeout(context.Background(), startTimeout)
+	defer cancel()
+
+	admin, err := open(c, "postgres")
+	if err != nil {
+		t.Fatalf("opening database connection: %s", err)
+	}
+
+	if _, err := admin.ExecContext(ctx, fmt.Sprintf("CREATE DATABASE %s TEMPLATE %s", name, templateDB)); err != nil {
+		admin.Close()
+		t.Fatalf("creating database %s: %s", name, err)
+	}
+
+	db, err := open(c, name)
+	if err != nil {
+		admin.Close()
+		t.Fatalf("opening database connection: %s", err)
+	}
+
+	docker.DumpLogsOnFailure(t, c)
+
+	var logs logBuffer
+	log, err := logger.New(logger.Config{
+		Service: "TEST",
+		Output:  &logs,
+		Level:   zap.NewAtomicLevelAt(zap.DebugLevel),
+	})
 	if err != nil {
 		t.Fatalf("logger error: %s", err)
 	}
 
-	teardown := func() {
-		t.Helper()
+	t.Cleanup(func() {
 		db.Close()
-		docker.StopContainer(t, c.Id)
+
+		ctx, cancel := context.WithTimeout(context.Background(), startTimeout)
+		defer cancel()
+
+		if _, err := admin.ExecContext(ctx, fmt.Sprintf("DROP DATABASE %s WITH (FORCE)", name)); err != nil {
+			t.Errorf("dropping database %s: %s", name, err)
+		}
+		admin.Close()
 
 		log.Sync()
+		if t.Failed() {
+			t.Logf("*************************** LOGS ***************************\n%s", logs.String())
+		}
+	})
 
-		w.Close()
-		var buf bytes.Buffer
-		io.Copy(&buf, r)
-		os.Stdout = old
-		fmt.Println("*************************** LOGS ***************************")
-		fmt.Println(buf.String())
-		fmt.Println("*************************** LOGS ***************************")
+	return log, db
+}
+
+// open connects to the named database of the container.
+func open(c *docker.Container, name string) (*sqlx.DB, error) {
+	db, err := database.Open(database.Config{
+		User:       "postgres",
+		Password:   "postgres",
+		Host:       c.Host,
+		Name:       name,
+		DisableTLS: true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("opening database %s: %w", name, err)
 	}
+	return db, nil
+}
+
+// logBuffer collects the logs of a test. It is safe for concurrent use.
+type logBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+// Write implements the io.Writer interface.
+func (b *logBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
 
-	return log, db, teardown
+// String returns the logs written so far.
+func (b *logBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
 }
 
 func StringPointer(s string) *string {
@@ -0,0 +1,193 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+// IDs of the users in the seed data.
+const (
+	SeedAdminID = "5cf37266-3473-4006-984f-9325122678b7"
+	SeedUserID  = "45b5fbd3-755f-4379-8f07-a58d4a30fa2f"
+)
+
+// WebTest sends requests to an http.Handler, usually the one returned by
+// handlers.APIMux, and asserts on the responses.
+type WebTest struct {
+	t       *testing.T
+	handler http.Handler
+	header  http.Header
+}
+
+// NewWebTest constructs a WebTest for the handler. The headers are sent with
+// every request, like an authorization token.
+func NewWebTest(t *testing.T, handler http.Handler, header http.Header) *WebTest {
+	return &WebTest{
+		t:       t,
+		handler: handler,
+		header:  header,
+	}
+}
+
+// Request is a request being built by a WebTest.
+type Request struct {
+	wt  *WebTest
+	req *http.Request
+}
+
+// Get starts a GET request for the path.
+func (wt *WebTest) Get(path string) *Request {
+	return wt.Request(http.MethodGet, path, nil)
+}
+
+// Post starts a POST request for the path with the JSON encoded body.
+func (wt *WebTest) Post(path string, body interface{}) *Request {
+	return wt.Request(http.MethodPost, path, body)
+}
+
+// Put starts a PUT request for the path with the JSON encoded body.
+func (wt *WebTest) Put(path string, body interface{}) *Request {
+	return wt.Request(http.MethodPut, path, body)
+}
+
+// Delete starts a DELETE request for the path.
+func (wt *WebTest) Delete(path string) *Request {
+	return wt.Request(http.MethodDelete, path, nil)
+}
+
+// Request starts a request. The body is sent as is when it is a string or a
+// byte slice and encoded to JSON otherwise.
+func (wt *WebTest) Request(method, path string, body interface{}) *Request {
+	wt.t.Helper()
+
+	var b []byte
+	switch v := body.(type) {
+	case nil:
+	case string:
+		b = []byte(v)
+	case []byte:
+		b = v
+	default:
+		var err error
+		if b, err = json.Marshal(v); err != nil {
+			wt.t.Fatalf("\t%s\tShould be able to encode the request body: %s.", Failed, err)
+		}
+	}
+
+	req := httptest.NewRequest(method, path, bytes.NewReader(b))
+	for k, v := range wt.header {
+		req.Header[k] = v
+	}
+	if b != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	return &Request{wt: wt, req: req}
+}
+
+// Header sets a header of the request.
+func (r *Request) Header(key, value string) *Request {
+	r.req.Header.Set(key, value)
+	return r
+}
+
+// Expect sends the request and returns the response to assert on.
+func (r *Request) Expect() *Response {
+	w := httptest.NewRecorder()
+	r.wt.handler.ServeHTTP(w, r.req)
+
+	return &Response{
+		t:      r.wt.t,
+		name:   r.req.Method + " " + r.req.URL.RequestURI(),
+		Result: w.Result(),
+		Body:   w.Body.Bytes(),
+	}
+}
+
+// Response is the response to a request sent by a WebTest. Failed assertions
+// stop the test.
+type Response struct {
+	t    *testing.T
+	name string
+
+	Result *http.Response
+	Body   []byte
+}
+
+// Status asserts the status code of the response.
+func (r *Response) Status(statusCode int) *Response {
+	r.t.Helper()
+
+	if r.Result.StatusCode != statusCode {
+		r.t.Fatalf("\t%s\t%s: Should receive a %d status code: %d: %s", Failed, r.name, statusCode, r.Result.StatusCode, r.Body)
+	}
+	r.t.Logf("\t%s\t%s: Should receive a %d status code.", Success, r.name, statusCode)
+
+	return r
+}
+
+// Header asserts the value of a header of the response.
+func (r *Response) Header(key, value string) *Response {
+	r.t.Helper()
+
+	if got := r.Result.Header.Get(key); got != value {
+		r.t.Fatalf("\t%s\t%s: Should receive the %s header %q: %q.", Failed, r.name, key, value, got)
+	}
+	r.t.Logf("\t%s\t%s: Should receive the %s header %q.", Success, r.name, key, value)
+
+	return r
+}
+
+// HeaderPresent asserts the response has the header.
+func (r *Response) HeaderPresent(key string) *Response {
+	r.t.Helper()
+
+	if r.Result.Header.Get(key) == "" {
+		r.t.Fatalf("\t%s\t%s: Should receive the %s header.", Failed, r.name, key)
+	}
+	r.t.Logf("\t%s\t%s: Should receive the %s header.", Success, r.name, key)
+
+	return r
+}
+
+// JSON asserts the response body is JSON and decodes it into the value.
+func (r *Response) JSON(val interface{}) *Response {
+	r.t.Helper()
+
+	if err := json.Unmarshal(r.Body, val); err != nil {
+		r.t.Fatalf("\t%s\t%s: Should receive a JSON body: %s: %s", Failed, r.name, err, r.Body)
+	}
+
+	return r
+}
+
+// Field asserts the value of a top level field of the JSON body. The value
+// is compared once encoded to and decoded from JSON, so numbers and structs
+// can be given as Go values.
+func (r *Response) Field(name string, value interface{}) *Response {
+	r.t.Helper()
+
+	var body map[string]interface{}
+	r.JSON(&body)
+
+	got, exists := body[name]
+	if !exists {
+		r.t.Fatalf("\t%s\t%s: Should receive the %q field: %s", Failed, r.name, name, r.Body)
+	}
+
+	var want interface{}
+	b, err := json.Marshal(value)
+	if err == nil {
+		err = json.Unmarshal(b, &want)
+	}
+	if err != nil || !reflect.DeepEqual(got, want) {
+		r.t.Fatalf("\t%s\t%s: Should receive the %q field %v: %v.", Failed, r.name, name, want, got)
+	}
+	r.t.Logf("\t%s\t%s: Should receive the %q field %v.", Success, r.name, name, want)
+
+	return r
+}
@@ -10,7 +10,7 @@ import (
 	"time"
 
 	"github.com/jmoiron/sqlx"
-	"github.com/mohammadhsn/ultimate-service/foundation/web"
+	"github.com/mohammadhsn/ultimate-service/foundation/logger"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
 	"go.uber.org/zap"
@@ -22,6 +22,7 @@ var (
 	ErrNotFound              = errors.New("not found")
 	ErrInvalidID             = errors.New("ID is not in its proper form")
 	ErrAuthenticationFailure = errors.New("authentication failed")
+	ErrVersionConflict       = errors.New("version conflict")
 )
 
 // Config is the required properties to use the database.
@@ -97,7 +98,10 @@ func StatusCheck(ctx context.Context, db *sqlx.DB) error {
 
 func NamedExecContext(ctx context.Context, log *zap.SugaredLogger, db *sqlx.DB, query string, data interface{}) error {
 	q := queryString(query, data)
-	log.Infow("database.NamedExecContext", "traceID", web.GetTraceID(ctx), "query", q)
+	start := time.Now()
+	defer func() {
+		logger.FromContext(ctx, log).Debugw("database.NamedExecContext", "query", q, "since", time.Since(start))
+	}()
 
 	ctx, span := otel.GetTracerProvider().Tracer("").Start(ctx, "database.query")
 	span.SetAttributes(attribute.String("query", q))
@@ -114,7 +118,10 @@ func NamedExecContext(ctx context.Context, log *zap.SugaredLogger, db *sqlx.DB,
 // collection of data to be unmarshalled into a slice.
 func NamedQuerySlice(ctx context.Context, log *zap.SugaredLogger, db *sqlx.DB, query string, data interface{}, dest interface{}) error {
 	q := queryString(query, data)
-	log.Infow("database.NamedQuerySlice", "traceID", web.GetTraceID(ctx), "query", q)
+	start := time.Now()
+	defer func() {
+		logger.FromContext(ctx, log).Debugw("database.NamedQuerySlice", "query", q, "since", time.Since(start))
+	}()
 
 	val := reflect.ValueOf(dest)
 
@@ -126,6 +133,7 @@ func NamedQuerySlice(ctx context.Context, log *zap.SugaredLogger, db *sqlx.DB, q
 	if err != nil {
 		return err
 	}
+	defer rows.Close()
 
 	slice := val.Elem()
 	for rows.Next() {
@@ -136,20 +144,28 @@ func NamedQuerySlice(ctx context.Context, log *zap.SugaredLogger, db *sqlx.DB, q
 		slice.Set(reflect.Append(slice, v.Elem()))
 	}
 
-	return nil
+	return rows.Err()
 }
 
 // NamedQueryStruct is a helper function for executing queries that return a
 // single value to be unmarshalled into a struct type.
 func NamedQueryStruct(ctx context.Context, log *zap.SugaredLogger, db *sqlx.DB, query string, data interface{}, dest interface{}) error {
 	q := queryString(query, data)
-	log.Infow("database.NamedQueryStruct", "traceID", web.GetTraceID(ctx), "query", q)
+	start := time.Now()
+	defer func() {
+		logger.FromContext(ctx, log).Debugw("database.NamedQueryStruct", "query", q, "since", time.Since(start))
+	}()
 
 	rows, err := db.NamedQueryContext(ctx, query, data)
 	if err != nil {
 		return err
 	}
+	defer rows.Close()
+
 	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return err
+		}
 		return ErrNotFound
 	}
 
@@ -160,14 +176,83 @@ func NamedQueryStruct(ctx context.Context, log *zap.SugaredLogger, db *sqlx.DB,
 	return nil
 }
 
+// Iterator walks the rows returned by a query one at a time, so results of
+// any size can be processed without holding them in memory. It must be
+// closed once done.
+type Iterator struct {
+	log   *zap.SugaredLogger
+	rows  *sqlx.Rows
+	query string
+	start time.Time
+}
+
+// NamedQueryIterator is a helper function for executing queries whose rows
+// are processed one at a time through the returned iterator.
+func NamedQueryIterator(ctx context.Context, log *zap.SugaredLogger, db *sqlx.DB, query string, data interface{}) (*Iterator, error) {
+	q := queryString(query, data)
+	start := time.Now()
+
+	rows, err := db.NamedQueryContext(ctx, query, data)
+	if err != nil {
+		logger.FromContext(ctx, log).Debugw("database.NamedQueryIterator", "query", q, "since", time.Since(start))
+		return nil, err
+	}
+
+	it := Iterator{
+		log:   logger.FromContext(ctx, log),
+		rows:  rows,
+		query: q,
+		start: start,
+	}
+
+	return &it, nil
+}
+
+// Next prepares the next row for scanning. It returns false once there are
+// no more rows or an error occurred, which Err reports.
+func (it *Iterator) Next() bool {
+	return it.rows.Next()
+}
+
+// Scan unmarshals the current row into the struct pointed to by dest.
+func (it *Iterator) Scan(dest interface{}) error {
+	return it.rows.StructScan(dest)
+}
+
+// Err returns the error, if any, that was encountered during iteration.
+func (it *Iterator) Err() error {
+	return it.rows.Err()
+}
+
+// Close releases the rows and logs the query with the time it took.
+func (it *Iterator) Close() error {
+	err := it.rows.Close()
+	it.log.Debugw("database.NamedQueryIterator", "query", it.query, "since", time.Since(it.start))
+	return err
+}
+
 // queryString provides a pretty print version of the query and parameters.
+// The placeholders are replaced in a single pass over the bound query, so
+// parameter values containing a question mark are never mistaken for one,
+// and the whitespace is cleaned up before the values are added.
 func queryString(query string, args ...interface{}) string {
 	query, params, err := sqlx.Named(query, args)
 	if err != nil {
 		return err.Error()
 	}
 
+	query = strings.ReplaceAll(query, "\t", "")
+	query = strings.ReplaceAll(query, "\n", " ")
+
+	var b strings.Builder
+	b.Grow(len(query))
+
 	for _, param := range params {
+		i := strings.IndexByte(query, '?')
+		if i == -1 {
+			break
+		}
+
 		var value string
 		switch v := param.(type) {
 		case string:
@@ -178,11 +263,11 @@ func queryString(query string, args ...interface{}) string {
 			value = fmt.Sprintf("%v", v)
 		}
 
-		query = strings.Replace(query, "?", value, 1)
+		b.WriteString(query[:i])
+		b.WriteString(value)
+		query = query[i+1:]
 	}
+	b.WriteString(query)
 
-	query = strings.ReplaceAll(query, "\t", "")
-	query = strings.ReplaceAll(query, "\n", " ")
-
-	return strings.Trim(query, " ")
+	return strings.Trim(b.String(), " ")
 }
@@ -0,0 +1,89 @@
+package database_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/mohammadhsn/ultimate-service/business/sys/database"
+)
+
+// Success and failure markers.
+const (
+	success = "\u2713"
+	failed  = "\u2717"
+)
+
+func TestQueryString(t *testing.T) {
+	t.Log("Given the need to log queries with their parameters.")
+
+	tt := []struct {
+		name  string
+		query string
+		data  interface{}
+		exp   string
+	}{
+		{
+			"named parameters",
+			"SELECT * FROM users\n\tWHERE user_id = :user_id AND version = :version",
+			map[string]interface{}{"user_id": "45b5fbd3", "version": 2},
+			`SELECT * FROM users WHERE user_id = "45b5fbd3" AND version = 2`,
+		},
+		{
+			"values with placeholders",
+			"UPDATE users SET name = :name, email = :email",
+			map[string]interface{}{"name": "who?", "email": "?@example.com"},
+			`UPDATE users SET name = "who?", email = "?@example.com"`,
+		},
+		{
+			"values with whitespace",
+			"UPDATE users SET name = :name",
+			map[string]interface{}{"name": "Bill\tKennedy\n"},
+			`UPDATE users SET name = "Bill\tKennedy\n"`,
+		},
+		{
+			"missing parameter",
+			"SELECT * FROM users WHERE name = :name",
+			map[string]interface{}{},
+			"could not find name name in map[string]interface {}{}",
+		},
+	}
+
+	for i, tc := range tt {
+		t.Logf("\tTest %d:\tWhen formatting a query with %s.", i, tc.name)
+		{
+			got := database.QueryString(tc.query, tc.data)
+			if got != tc.exp {
+				t.Fatalf("\t%s\tShould get the expected query : got %q, exp %q", failed, got, tc.exp)
+			}
+			t.Logf("\t%s\tShould get the expected query.", success)
+		}
+	}
+}
+
+func FuzzQueryString(f *testing.F) {
+	f.Add("SELECT * FROM users WHERE name = :name AND email = :email", "Bill", "bill@ardanlabs.com")
+	f.Add("UPDATE users SET name = :name WHERE email = :email", "who?", "??")
+	f.Add("SELECT ':name' ? FROM users WHERE name = :name", "?", "")
+	f.Add("SELECT date_created::date FROM users\n\tWHERE email = :email", "", "a\tb\nc")
+	f.Add(":name:email::", "\"", "\\")
+
+	f.Fuzz(func(t *testing.T, query, name, email string) {
+		data := map[string]interface{}{"name": name, "email": email}
+
+		// Arbitrary queries must never panic.
+		database.QueryString(query, data)
+
+		// The values of a well formed query must appear as they are, in
+		// order, whatever they contain.
+		got := database.QueryString("SELECT * FROM users WHERE name = :name AND email = :email", data)
+		exp := fmt.Sprintf("SELECT * FROM users WHERE name = %q AND email = %q", name, email)
+		if got != exp {
+			t.Fatalf("\t%s\tShould get the expected query : got %q, exp %q", failed, got, exp)
+		}
+
+		if strings.Count(got, "?") != strings.Count(exp, "?") {
+			t.Fatalf("\t%s\tShould keep the question marks of the values : %q", failed, got)
+		}
+	})
+}
@@ -0,0 +1,4 @@
+package database
+
+// QueryString exports queryString for the tests of the package.
+var QueryString = queryString
@@ -0,0 +1,161 @@
+package database_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"sync/atomic"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/mohammadhsn/ultimate-service/business/sys/database"
+	"go.uber.org/zap"
+)
+
+// fakeRows returns the numbers as rows until n, failing at fail when set. A
+// negative n never runs out of rows.
+type fakeRows struct {
+	n      int
+	fail   int
+	err    error
+	next   int
+	closed int32
+}
+
+func (r *fakeRows) Columns() []string { return []string{"id"} }
+
+func (r *fakeRows) Close() error {
+	atomic.StoreInt32(&r.closed, 1)
+	return nil
+}
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.err != nil && r.next == r.fail {
+		return r.err
+	}
+	if r.n >= 0 && r.next >= r.n {
+		return io.EOF
+	}
+	dest[0] = int64(r.next)
+	r.next++
+	return nil
+}
+
+// fakeConn answers every query with its rows.
+type fakeConn struct {
+	rows *fakeRows
+}
+
+func (c fakeConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (c fakeConn) Close() error                        { return nil }
+func (c fakeConn) Begin() (driver.Tx, error)           { return nil, errors.New("not supported") }
+
+func (c fakeConn) QueryContext(context.Context, string, []driver.NamedValue) (driver.Rows, error) {
+	return c.rows, nil
+}
+
+// fakeConnector connects to a database holding the rows.
+type fakeConnector struct {
+	rows *fakeRows
+}
+
+func (c fakeConnector) Connect(context.Context) (driver.Conn, error) { return fakeConn(c), nil }
+func (c fakeConnector) Driver() driver.Driver                        { return nil }
+
+func TestIterator(t *testing.T) {
+	t.Log("Given the need to walk query results one row at a time.")
+
+	type row struct {
+		ID int `db:"id"`
+	}
+
+	query := func(ctx context.Context, rows *fakeRows) (*database.Iterator, error) {
+		db := sqlx.NewDb(sql.OpenDB(fakeConnector{rows}), "postgres")
+		return database.NamedQueryIterator(ctx, zap.NewNop().Sugar(), db, "SELECT id FROM numbers", struct{}{})
+	}
+
+	testID := 0
+	t.Logf("\tTest %d:\tWhen walking every row.", testID)
+	{
+		rows := fakeRows{n: 3}
+		it, err := query(context.Background(), &rows)
+		if err != nil {
+			t.Fatalf("\t%s\tTest %d:\tShould be able to run the query : %s.", failed, testID, err)
+		}
+
+		var ids []int
+		for it.Next() {
+			var r row
+			if err := it.Scan(&r); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to scan a row : %s.", failed, testID, err)
+			}
+			ids = append(ids, r.ID)
+		}
+		if it.Err() != nil || len(ids) != 3 || ids[2] != 2 {
+			t.Fatalf("\t%s\tTest %d:\tShould return every row : got %v, %v.", failed, testID, ids, it.Err())
+		}
+		t.Logf("\t%s\tTest %d:\tShould return every row.", success, testID)
+
+		if err := it.Close(); err != nil || atomic.LoadInt32(&rows.closed) != 1 {
+			t.Fatalf("\t%s\tTest %d:\tShould close the rows : %v.", failed, testID, err)
+		}
+		t.Logf("\t%s\tTest %d:\tShould close the rows.", success, testID)
+	}
+
+	testID++
+	t.Logf("\tTest %d:\tWhen the rows fail half way.", testID)
+	{
+		errBroken := errors.New("connection reset")
+		rows := fakeRows{n: 10, fail: 2, err: errBroken}
+		it, err := query(context.Background(), &rows)
+		if err != nil {
+			t.Fatalf("\t%s\tTest %d:\tShould be able to run the query : %s.", failed, testID, err)
+		}
+
+		var n int
+		for it.Next() {
+			n++
+		}
+		if n != 2 || !errors.Is(it.Err(), errBroken) {
+			t.Fatalf("\t%s\tTest %d:\tShould stop at the failure and report it : got %d rows, %v.", failed, testID, n, it.Err())
+		}
+		t.Logf("\t%s\tTest %d:\tShould stop at the failure and report it.", success, testID)
+
+		it.Close()
+		if atomic.LoadInt32(&rows.closed) != 1 {
+			t.Fatalf("\t%s\tTest %d:\tShould close the rows.", failed, testID)
+		}
+		t.Logf("\t%s\tTest %d:\tShould close the rows.", success, testID)
+	}
+
+	testID++
+	t.Logf("\tTest %d:\tWhen the context is canceled half way.", testID)
+	{
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		rows := fakeRows{n: -1}
+		it, err := query(ctx, &rows)
+		if err != nil {
+			t.Fatalf("\t%s\tTest %d:\tShould be able to run the query : %s.", failed, testID, err)
+		}
+
+		it.Next()
+		cancel()
+		for it.Next() {
+		}
+
+		if !errors.Is(it.Err(), context.Canceled) {
+			t.Fatalf("\t%s\tTest %d:\tShould stop with the context error : got %v.", failed, testID, it.Err())
+		}
+		t.Logf("\t%s\tTest %d:\tShould stop with the context error.", success, testID)
+
+		it.Close()
+		if atomic.LoadInt32(&rows.closed) != 1 {
+			t.Fatalf("\t%s\tTest %d:\tShould close the rows.", failed, testID)
+		}
+		t.Logf("\t%s\tTest %d:\tShould close the rows.", success, testID)
+	}
+}
@@ -3,6 +3,8 @@ package metrics
 import (
 	"context"
 	"expvar"
+	"strconv"
+	"time"
 )
 
 // This holds the single instance of the metrics value needed for
@@ -14,10 +16,17 @@ var m *metrics
 // metrics represents the set of metrics we gather. These fields are
 // safe to be accessed concurrently thanks to expvar. No extra abstraction is required.
 type metrics struct {
-	goroutine *expvar.Int
-	requests  *expvar.Int
-	errors    *expvar.Int
-	panics    *expvar.Int
+	goroutine     *expvar.Int
+	requests      *expvar.Int
+	errors        *expvar.Int
+	panics        *expvar.Int
+	unhandled     *expvar.Int
+	statusCodes   *expvar.Map
+	statusClasses *expvar.Map
+	bytes         *expvar.Int
+	latency       *expvar.Int
+	ttfb          *expvar.Int
+	rateLimited   *expvar.Int
 }
 
 // init constructs the metrics value that will be used to capture metrics.
@@ -26,10 +35,17 @@ type metrics struct {
 // sure this initialization only happens once.
 func init() {
 	m = &metrics{
-		goroutine: expvar.NewInt("goroutines"),
-		requests:  expvar.NewInt("requests"),
-		errors:    expvar.NewInt("errors"),
-		panics:    expvar.NewInt("panics"),
+		goroutine:     expvar.NewInt("goroutines"),
+		requests:      expvar.NewInt("requests"),
+		errors:        expvar.NewInt("errors"),
+		panics:        expvar.NewInt("panics"),
+		unhandled:     expvar.NewInt("unhandled_errors"),
+		statusCodes:   expvar.NewMap("status_codes"),
+		statusClasses: expvar.NewMap("status_classes"),
+		bytes:         expvar.NewInt("response_bytes"),
+		latency:       expvar.NewInt("latency_us_total"),
+		ttfb:          expvar.NewInt("ttfb_us_total"),
+		rateLimited:   expvar.NewInt("rate_limited"),
 	}
 }
 
@@ -58,7 +74,7 @@ func AddRequests(ctx context.Context) {
 	}
 }
 
-// AddErrors increments the error metric by 1.
+// AddErrors increments the metric of errors returned by handlers by 1.
 func AddErrors(ctx context.Context) {
 	if v, ok := ctx.Value(key).(*metrics); ok {
 		v.errors.Add(1)
@@ -71,3 +87,32 @@ func AddPanics(ctx context.Context) {
 		v.panics.Add(1)
 	}
 }
+
+// AddUnhandledErrors increments the metric of errors that escaped the
+// middleware chain by 1.
+func AddUnhandledErrors(ctx context.Context) {
+	if v, ok := ctx.Value(key).(*metrics); ok {
+		v.unhandled.Add(1)
+	}
+}
+
+// AddRateLimited increments the rate limited requests metric by 1.
+func AddRateLimited(ctx context.Context) {
+	if v, ok := ctx.Value(key).(*metrics); ok {
+		v.rateLimited.Add(1)
+	}
+}
+
+// AddResponse records the status code and its class, like 4xx, the size and
+// timings of a response. The durations are accumulated in microseconds, as
+// most requests take less than a millisecond. Dividing them by the requests
+// metric gives the average.
+func AddResponse(ctx context.Context, statusCode int, size int64, ttfb time.Duration, latency time.Duration) {
+	if v, ok := ctx.Value(key).(*metrics); ok {
+		v.statusCodes.Add(strconv.Itoa(statusCode), 1)
+		v.statusClasses.Add(strconv.Itoa(statusCode/100)+"xx", 1)
+		v.bytes.Add(size)
+		v.ttfb.Add(ttfb.Microseconds())
+		v.latency.Add(latency.Microseconds())
+	}
+}
@@ -68,8 +68,14 @@ func GenerateId() string {
 	return uuid.NewString()
 }
 
-// CheckId validates that the format of an id is valid.
+// CheckId validates that the format of an id is valid. Only the canonical
+// form of a UUID is accepted, not the braced, URN or undashed forms the uuid
+// package also parses, since those are not valid ids for the database.
 func CheckId(id string) error {
+	if len(id) != 36 {
+		return ErrInvalidID
+	}
+
 	if _, err := uuid.Parse(id); err != nil {
 		return ErrInvalidID
 	}
@@ -0,0 +1,165 @@
+package validate_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/mohammadhsn/ultimate-service/business/sys/validate"
+)
+
+// Success and failure markers.
+const (
+	success = "\u2713"
+	failed  = "\u2717"
+)
+
+// newUser is a request model using the kinds of tags the API models use.
+type newUser struct {
+	Name            string   `json:"name" validate:"required"`
+	Email           string   `json:"email" validate:"required,email"`
+	Roles           []string `json:"roles" validate:"required,dive,oneof=ADMIN USER"`
+	Password        string   `json:"password" validate:"required,min=6"`
+	PasswordConfirm string   `json:"passwordConfirm" validate:"eqfield=Password"`
+	ManagerID       string   `json:"managerId" validate:"omitempty,uuid"`
+}
+
+func TestCheckId(t *testing.T) {
+	t.Log("Given the need to validate ids.")
+
+	tt := []struct {
+		id    string
+		valid bool
+	}{
+		{"5cf37266-3473-4006-984f-9325122678b7", true},
+		{"5CF37266-3473-4006-984F-9325122678B7", true},
+		{"", false},
+		{"123", false},
+		{"5cf37266347340069 84f9325122678b7", false},
+		{"5cf37266347340069984f9325122678b7", false},
+		{"{5cf37266-3473-4006-984f-9325122678b7}", false},
+		{"urn:uuid:5cf37266-3473-4006-984f-9325122678b7", false},
+		{"5cf37266-3473-4006-984f-9325122678bz", false},
+	}
+
+	for i, tc := range tt {
+		t.Logf("\tTest %d:\tWhen checking %q.", i, tc.id)
+		{
+			err := validate.CheckId(tc.id)
+			if tc.valid && err != nil {
+				t.Fatalf("\t%s\tShould accept the id : %s", failed, err)
+			}
+			if !tc.valid && !errors.Is(err, validate.ErrInvalidID) {
+				t.Fatalf("\t%s\tShould reject the id : %v", failed, err)
+			}
+			t.Logf("\t%s\tShould get the expected result.", success)
+		}
+	}
+}
+
+func FuzzCheck(f *testing.F) {
+	f.Add([]byte(`{"name":"Bill","email":"bill@ardanlabs.com","roles":["ADMIN"],"password":"gophers","passwordConfirm":"gophers"}`))
+	f.Add([]byte(`{"name":"","email":"bill","roles":["ROOT"],"password":"go","passwordConfirm":"gophers","managerId":"123"}`))
+	f.Add([]byte(`{"roles":[""],"managerId":"5cf37266-3473-4006-984f-9325122678b7"}`))
+	f.Add([]byte(`{"email":"\u0000@\u00e9.com","roles":null}`))
+	f.Add([]byte(`{}`))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var nu newUser
+		if err := json.Unmarshal(data, &nu); err != nil {
+			return
+		}
+
+		err := validate.Check(nu)
+		if err == nil {
+			return
+		}
+
+		var fields validate.FieldErrors
+		if !errors.As(err, &fields) {
+			t.Fatalf("\t%s\tShould get field errors : %T %v", failed, err, err)
+		}
+		if len(fields) == 0 {
+			t.Fatalf("\t%s\tShould get at least one field error.", failed)
+		}
+
+		// The field errors are sent to clients as JSON.
+		var decoded []validate.FieldError
+		if err := json.Unmarshal([]byte(fields.Error()), &decoded); err != nil {
+			t.Fatalf("\t%s\tShould encode the field errors as JSON : %s", failed, err)
+		}
+		for _, fe := range decoded {
+			if fe.Field == "" || fe.Error == "" {
+				t.Fatalf("\t%s\tShould name the field and the error : %+v", failed, fe)
+			}
+		}
+	})
+}
+
+func FuzzCheckId(f *testing.F) {
+	f.Add("5cf37266-3473-4006-984f-9325122678b7")
+	f.Add("45B5FBD3-755F-4379-8F07-A58D4A30FA2F")
+	f.Add("{5cf37266-3473-4006-984f-9325122678b7}")
+	f.Add("urn:uuid:5cf37266-3473-4006-984f-9325122678b7")
+	f.Add("5cf37266347340069984f9325122678b7")
+	f.Add("123")
+	f.Add("")
+
+	f.Fuzz(func(t *testing.T, id string) {
+		if err := validate.CheckId(id); err != nil {
+			if !errors.Is(err, validate.ErrInvalidID) {
+				t.Fatalf("\t%s\tShould get ErrInvalidID : %v", failed, err)
+			}
+			return
+		}
+
+		// An accepted id must be the canonical form of a UUID, as it is
+		// used as is in the queries.
+		u, err := uuid.Parse(id)
+		if err != nil {
+			t.Fatalf("\t%s\tShould be a UUID : %s", failed, err)
+		}
+		if u.String() != strings.ToLower(id) {
+			t.Fatalf("\t%s\tShould be in the canonical form : got %q, exp %q", failed, id, u.String())
+		}
+	})
+}
+
+func FuzzCause(f *testing.F) {
+	f.Add("not found", uint8(0), false)
+	f.Add("ID is not in its proper form", uint8(3), true)
+	f.Add("%w %v %%", uint8(10), false)
+	f.Add("", uint8(255), true)
+
+	f.Fuzz(func(t *testing.T, msg string, depth uint8, request bool) {
+		root := errors.New(msg)
+
+		var err error = root
+		if request {
+			err = validate.NewRequestError(err, 400)
+		}
+		for i := 0; i < int(depth); i++ {
+			err = fmt.Errorf("wrap %d: %w", i, err)
+		}
+
+		got := validate.Cause(err)
+		if errors.Unwrap(got) != nil {
+			t.Fatalf("\t%s\tShould get an error that wraps nothing : %v", failed, got)
+		}
+
+		switch {
+		case request:
+			var re *validate.RequestError
+			if !errors.As(got, &re) || re.Err != root {
+				t.Fatalf("\t%s\tShould stop at the request error : %T %v", failed, got, got)
+			}
+		default:
+			if got != root {
+				t.Fatalf("\t%s\tShould get the root error : %T %v", failed, got, got)
+			}
+		}
+	})
+}
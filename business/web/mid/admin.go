@@ -0,0 +1,60 @@
+package mid
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/mohammadhsn/ultimate-service/business/sys/validate"
+	"github.com/mohammadhsn/ultimate-service/foundation/web"
+)
+
+// Admin only lets requests through that carry the admin key as a bearer
+// token in the Authorization header. An empty key rejects every request, so
+// the routes behind it are off until a key is configured.
+func Admin(key string) web.Middleware {
+	return func(handler web.Handler) web.Handler {
+		return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+			if key == "" {
+				return validate.NewRequestError(errors.New("admin access is not configured"), http.StatusForbidden)
+			}
+
+			if !isAdmin(r, key) {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				return validate.NewRequestError(errors.New("admin access required"), http.StatusUnauthorized)
+			}
+
+			return handler(ctx, w, r)
+		}
+	}
+}
+
+// AdminSubject returns the subject of the requests carrying the admin key as
+// a bearer token, for the rate limiter and the idempotency keys. Requests
+// without the key, or with a wrong one, have no subject.
+func AdminSubject(key string) func(r *http.Request) string {
+	return func(r *http.Request) string {
+		if !isAdmin(r, key) {
+			return ""
+		}
+		return "admin"
+	}
+}
+
+// isAdmin reports whether the request carries the admin key, which must not
+// be empty.
+func isAdmin(r *http.Request, key string) bool {
+	token, ok := bearerToken(r)
+	return ok && key != "" && subtle.ConstantTimeCompare([]byte(token), []byte(key)) == 1
+}
+
+// bearerToken returns the bearer token of the Authorization header.
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") || token == "" {
+		return "", false
+	}
+	return token, true
+}
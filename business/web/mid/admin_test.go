@@ -0,0 +1,88 @@
+package mid_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/mohammadhsn/ultimate-service/business/web/mid"
+	"github.com/mohammadhsn/ultimate-service/foundation/web"
+	"go.uber.org/zap"
+)
+
+func TestAdmin(t *testing.T) {
+	t.Log("Given the need to keep admin routes to holders of the admin key.")
+
+	newApp := func(key string) *web.App {
+		log := zap.NewNop().Sugar()
+		app := web.NewApp(make(chan os.Signal, 1), log, mid.Errors(log))
+		app.Handle(http.MethodGet, "", "/export", func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+			return web.Respond(ctx, w, struct{}{}, http.StatusOK)
+		}, mid.Admin(key))
+		return app
+	}
+
+	tt := []struct {
+		name          string
+		key           string
+		authorization string
+		status        int
+	}{
+		{"request with the admin key", "s3cret", "Bearer s3cret", http.StatusOK},
+		{"request with a lowercase scheme", "s3cret", "bearer s3cret", http.StatusOK},
+		{"request without credentials", "s3cret", "", http.StatusUnauthorized},
+		{"request with another key", "s3cret", "Bearer guess", http.StatusUnauthorized},
+		{"request with another scheme", "s3cret", "Basic s3cret", http.StatusUnauthorized},
+		{"request while no key is configured", "", "Bearer ", http.StatusForbidden},
+	}
+
+	for testId, tst := range tt {
+		t.Logf("\tTest %d:\tWhen handling a %s.", testId, tst.name)
+		{
+			r := httptest.NewRequest(http.MethodGet, "/export", nil)
+			if tst.authorization != "" {
+				r.Header.Set("Authorization", tst.authorization)
+			}
+			w := httptest.NewRecorder()
+			newApp(tst.key).ServeHTTP(w, r)
+
+			if w.Code != tst.status {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a %d status code : %d.", failed, testId, tst.status, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a %d status code.", success, testId, tst.status)
+		}
+	}
+}
+
+func TestAdminSubject(t *testing.T) {
+	t.Log("Given the need to tell the requests made with the admin key apart.")
+
+	tt := []struct {
+		name          string
+		key           string
+		authorization string
+		subject       string
+	}{
+		{"request with the admin key", "s3cret", "Bearer s3cret", "admin"},
+		{"request without credentials", "s3cret", "", ""},
+		{"request with another key", "s3cret", "Bearer guess", ""},
+		{"request while no key is configured", "", "Bearer ", ""},
+	}
+
+	for testId, tst := range tt {
+		t.Logf("\tTest %d:\tWhen handling a %s.", testId, tst.name)
+		{
+			r := httptest.NewRequest(http.MethodGet, "/users", nil)
+			if tst.authorization != "" {
+				r.Header.Set("Authorization", tst.authorization)
+			}
+
+			if got := mid.AdminSubject(tst.key)(r); got != tst.subject {
+				t.Fatalf("\t%s\tTest %d:\tShould have the subject %q : %q.", failed, testId, tst.subject, got)
+			}
+			t.Logf("\t%s\tTest %d:\tShould have the subject %q.", success, testId, tst.subject)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package mid
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mohammadhsn/ultimate-service/foundation/web"
+)
+
+// CORSConfig contains the cross-origin settings for the API. An origin of
+// "*" allows every origin.
+type CORSConfig struct {
+	AllowedOrigins []string
+	AllowedMethods []string
+	AllowedHeaders []string
+	MaxAge         time.Duration
+}
+
+// CORS adds the cross-origin resource sharing headers to responses for
+// allowed origins and answers preflight requests directly.
+func CORS(cfg CORSConfig) web.Middleware {
+	allowAll := false
+	origins := make(map[string]bool)
+	for _, o := range cfg.AllowedOrigins {
+		if o == "*" {
+			allowAll = true
+		}
+		origins[strings.ToLower(o)] = true
+	}
+
+	methods := strings.Join(cfg.AllowedMethods, ", ")
+	headers := strings.Join(cfg.AllowedHeaders, ", ")
+	maxAge := strconv.Itoa(int(cfg.MaxAge.Seconds()))
+
+	return func(handler web.Handler) web.Handler {
+		return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+			origin := r.Header.Get("Origin")
+			if origin == "" {
+				return handler(ctx, w, r)
+			}
+
+			// The response differs per origin, caches must take that into account.
+			w.Header().Add("Vary", "Origin")
+
+			if !allowAll && !origins[strings.ToLower(origin)] {
+				return handler(ctx, w, r)
+			}
+
+			if allowAll {
+				w.Header().Set("Access-Control-Allow-Origin", "*")
+			} else {
+				w.Header().Set("Access-Control-Allow-Origin", origin)
+			}
+
+			// Answer preflight requests here, they never reach the handler.
+			if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+				w.Header().Set("Access-Control-Allow-Methods", methods)
+				w.Header().Set("Access-Control-Allow-Headers", headers)
+				if cfg.MaxAge > 0 {
+					w.Header().Set("Access-Control-Max-Age", maxAge)
+				}
+				return web.Respond(ctx, w, nil, http.StatusNoContent)
+			}
+
+			return handler(ctx, w, r)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package mid_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/mohammadhsn/ultimate-service/business/web/mid"
+	"github.com/mohammadhsn/ultimate-service/foundation/web"
+	"go.uber.org/zap"
+)
+
+const (
+	success = "\u2713"
+	failed  = "\u2717"
+)
+
+func TestCORS(t *testing.T) {
+	t.Log("Given the need to share the API with browser clients of other origins.")
+
+	newApp := func(origins ...string) *web.App {
+		app := web.NewApp(make(chan os.Signal, 1), zap.NewNop().Sugar(), mid.CORS(mid.CORSConfig{
+			AllowedOrigins: origins,
+			AllowedMethods: []string{"GET", "POST"},
+			AllowedHeaders: []string{"Content-Type", "If-Match"},
+			MaxAge:         time.Hour,
+		}))
+		app.Handle(http.MethodGet, "", "/users", func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+			return web.Respond(ctx, w, struct{}{}, http.StatusOK)
+		})
+		return app
+	}
+
+	tt := []struct {
+		name      string
+		app       *web.App
+		method    string
+		origin    string
+		preflight bool
+		status    int
+		allow     string
+		vary      bool
+	}{
+		{"request without an origin", newApp("https://example.com"), http.MethodGet, "", false, http.StatusOK, "", false},
+		{"request from an allowed origin", newApp("https://example.com"), http.MethodGet, "https://example.com", false, http.StatusOK, "https://example.com", true},
+		{"request matching the origin case-insensitively", newApp("https://Example.com"), http.MethodGet, "https://example.COM", false, http.StatusOK, "https://example.COM", true},
+		{"request from another origin", newApp("https://example.com"), http.MethodGet, "https://evil.com", false, http.StatusOK, "", true},
+		{"request with every origin allowed", newApp("*"), http.MethodGet, "https://evil.com", false, http.StatusOK, "*", true},
+		{"preflight from an allowed origin", newApp("https://example.com"), http.MethodOptions, "https://example.com", true, http.StatusNoContent, "https://example.com", true},
+		{"preflight from another origin", newApp("https://example.com"), http.MethodOptions, "https://evil.com", true, http.StatusNoContent, "", true},
+	}
+
+	for testId, tst := range tt {
+		t.Logf("\tTest %d:\tWhen handling a %s.", testId, tst.name)
+		{
+			r := httptest.NewRequest(tst.method, "/users", nil)
+			if tst.origin != "" {
+				r.Header.Set("Origin", tst.origin)
+			}
+			if tst.preflight {
+				r.Header.Set("Access-Control-Request-Method", "POST")
+			}
+			w := httptest.NewRecorder()
+			tst.app.ServeHTTP(w, r)
+
+			if w.Code != tst.status {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a %d status code : %d.", failed, testId, tst.status, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a %d status code.", success, testId, tst.status)
+
+			if got := w.Header().Get("Access-Control-Allow-Origin"); got != tst.allow {
+				t.Fatalf("\t%s\tTest %d:\tShould allow the origin %q : %q.", failed, testId, tst.allow, got)
+			}
+			t.Logf("\t%s\tTest %d:\tShould allow the origin %q.", success, testId, tst.allow)
+
+			if got := w.Header().Get("Vary") == "Origin"; got != tst.vary {
+				t.Fatalf("\t%s\tTest %d:\tShould vary on the origin %t : %v.", failed, testId, tst.vary, w.Header().Values("Vary"))
+			}
+			t.Logf("\t%s\tTest %d:\tShould vary on the origin %t.", success, testId, tst.vary)
+
+			preflight := tst.preflight && tst.allow != ""
+			methods := w.Header().Get("Access-Control-Allow-Methods")
+			headers := w.Header().Get("Access-Control-Allow-Headers")
+			maxAge := w.Header().Get("Access-Control-Max-Age")
+			switch {
+			case preflight && (methods != "GET, POST" || headers != "Content-Type, If-Match" || maxAge != "3600"):
+				t.Fatalf("\t%s\tTest %d:\tShould answer the preflight : %q %q %q.", failed, testId, methods, headers, maxAge)
+			case !preflight && (methods != "" || headers != "" || maxAge != ""):
+				t.Fatalf("\t%s\tTest %d:\tShould not answer as a preflight : %q %q %q.", failed, testId, methods, headers, maxAge)
+			}
+			t.Logf("\t%s\tTest %d:\tShould only answer allowed preflights.", success, testId)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package mid
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mohammadhsn/ultimate-service/foundation/logger"
+	"github.com/mohammadhsn/ultimate-service/foundation/web"
+	"go.uber.org/zap"
+)
+
+// DebugHeader is the request header carrying a signed debug token.
+const DebugHeader = "X-Debug-Log"
+
+// DebugLog enables debug logging for a single request when it carries a valid
+// token signed with the key. Requests without the header, or with an invalid
+// or expired token, are logged at the configured level. An empty key turns
+// the feature off. It must run after the Logger middleware, which attaches
+// the request logger to the context.
+func DebugLog(log *zap.SugaredLogger, key string) web.Middleware {
+	return func(handler web.Handler) web.Handler {
+		return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+			token := r.Header.Get(DebugHeader)
+			if key == "" || token == "" {
+				return handler(ctx, w, r)
+			}
+
+			if err := verifyDebugToken([]byte(key), token, time.Now()); err != nil {
+				logger.FromContext(ctx, log).Warnw("debug token rejected", "ERROR", err)
+				return handler(ctx, w, r)
+			}
+
+			logger.EnableDebug(ctx)
+
+			return handler(ctx, w, r)
+		}
+	}
+}
+
+// NewDebugToken generates a token for the debug header that is valid until
+// the expiration time.
+func NewDebugToken(key string, expires time.Time) string {
+	exp := strconv.FormatInt(expires.Unix(), 10)
+	return exp + "." + signDebugToken([]byte(key), exp)
+}
+
+// verifyDebugToken checks the signature and expiration of a debug token.
+func verifyDebugToken(key []byte, token string, now time.Time) error {
+	exp, sig, ok := strings.Cut(token, ".")
+	if !ok {
+		return errors.New("malformed token")
+	}
+
+	if !hmac.Equal([]byte(sig), []byte(signDebugToken(key, exp))) {
+		return errors.New("invalid signature")
+	}
+
+	unix, err := strconv.ParseInt(exp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("parsing expiration: %w", err)
+	}
+
+	if now.After(time.Unix(unix, 0)) {
+		return errors.New("token expired")
+	}
+
+	return nil
+}
+
+// signDebugToken returns the HMAC-SHA256 signature of the expiration.
+func signDebugToken(key []byte, exp string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(exp))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -2,7 +2,9 @@ package mid
 
 import (
 	"context"
+	"github.com/mohammadhsn/ultimate-service/business/sys/metrics"
 	"github.com/mohammadhsn/ultimate-service/business/sys/validate"
+	"github.com/mohammadhsn/ultimate-service/foundation/logger"
 	"github.com/mohammadhsn/ultimate-service/foundation/web"
 	"go.uber.org/zap"
 	"net/http"
@@ -27,8 +29,10 @@ func Errors(log *zap.SugaredLogger) web.Middleware {
 
 			// Run the next handler and catch any propagated error.
 			if err := handler(ctx, w, r); err != nil {
+				metrics.AddErrors(ctx)
+
 				// Log the error.
-				log.Errorw("ERROR", "traceID", v.TraceID, "ERROR", err)
+				logger.FromContext(ctx, log).Errorw("ERROR", "ERROR", err)
 
 				// Build out the error response.
 				var er validate.ErrorResponse
@@ -52,9 +56,12 @@ func Errors(log *zap.SugaredLogger) web.Middleware {
 					status = http.StatusInternalServerError
 				}
 
-				// Respond with the error back to the client.
-				if err := web.Respond(ctx, w, er, status); err != nil {
-					return err
+				// Respond with the error back to the client, unless the
+				// handler already started writing the response.
+				if v.FirstByte.IsZero() {
+					if err := web.Respond(ctx, w, er, status); err != nil {
+						return err
+					}
 				}
 
 				// If we receive the shutdown err we need to return it
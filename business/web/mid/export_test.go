@@ -0,0 +1,16 @@
+package mid
+
+import "time"
+
+// ClientKey exports clientKey for the tests of the package.
+var ClientKey = clientKey
+
+// NewLimiter exports the token buckets of the limiter for the tests of the
+// package. The returned function takes a token for the key.
+func NewLimiter(rate float64, burst int) func(key string, now time.Time) (allowed bool, remaining int, retryAfter time.Duration) {
+	l := newLimiter(rate, burst)
+	return func(key string, now time.Time) (bool, int, time.Duration) {
+		res := l.allow(key, now)
+		return res.allowed, res.remaining, res.retryAfter
+	}
+}
@@ -0,0 +1,247 @@
+package mid
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/andybalholm/brotli"
+	"github.com/mohammadhsn/ultimate-service/business/data/store/idempotency"
+	"github.com/mohammadhsn/ultimate-service/business/sys/database"
+	"github.com/mohammadhsn/ultimate-service/business/sys/validate"
+	"github.com/mohammadhsn/ultimate-service/foundation/logger"
+	"github.com/mohammadhsn/ultimate-service/foundation/web"
+	"go.uber.org/zap"
+)
+
+// IdempotencyHeader is the request header carrying the idempotency key.
+const IdempotencyHeader = "Idempotency-Key"
+
+const (
+	// maxIdempotencyKey is the longest idempotency key accepted.
+	maxIdempotencyKey = 255
+
+	// maxIdempotentBody is the largest request body that can be fingerprinted.
+	maxIdempotentBody = 1 << 20
+
+	// storeTimeout is how long completing or releasing a key may take once
+	// the request is handled.
+	storeTimeout = 5 * time.Second
+)
+
+// IdempotencyStore keeps the idempotency keys and their responses, it is
+// implemented by idempotency.Store.
+type IdempotencyStore interface {
+	Reserve(ctx context.Context, key idempotency.Key) (stored idempotency.Key, reserved bool, err error)
+	Complete(ctx context.Context, key idempotency.Key) error
+	Release(ctx context.Context, key string) error
+}
+
+// IdempotencyConfig contains the settings of the idempotency middleware.
+type IdempotencyConfig struct {
+	Store IdempotencyStore
+
+	// TTL is how long the responses are kept for replay.
+	TTL time.Duration
+
+	// TrustedProxies and Subject identify the client of a request like they
+	// do for the rate limiter, keys are only shared by requests of the same
+	// client.
+	TrustedProxies []*net.IPNet
+	Subject        func(r *http.Request) string
+}
+
+// Idempotency makes POST requests carrying an Idempotency-Key header safe to
+// retry. The response of the first request made with a key is stored and
+// replayed for the requests repeating it until the key expires. Keys are
+// scoped to the client, the method and the path of the request. A repeat
+// arriving while the first request is still processed gets a 409, reusing a
+// key for a different request gets a 422. Requests failing with an error or
+// a 5xx response release the key so they can be retried. Responses are
+// stored uncompressed, so a repeat is answered whatever encodings it accepts.
+func Idempotency(log *zap.SugaredLogger, cfg IdempotencyConfig) web.Middleware {
+	return func(handler web.Handler) web.Handler {
+		return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+			key := r.Header.Get(IdempotencyHeader)
+			if r.Method != http.MethodPost || key == "" {
+				return handler(ctx, w, r)
+			}
+
+			if len(key) > maxIdempotencyKey {
+				return validate.NewRequestError(errors.New("idempotency key is too long"), http.StatusBadRequest)
+			}
+
+			body, err := io.ReadAll(io.LimitReader(r.Body, maxIdempotentBody+1))
+			if err != nil {
+				return validate.NewRequestError(errors.New("unable to read payload"), http.StatusBadRequest)
+			}
+			if len(body) > maxIdempotentBody {
+				return validate.NewRequestError(errors.New("payload too large"), http.StatusRequestEntityTooLarge)
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			scoped := clientKey(r, cfg.Subject, cfg.TrustedProxies) + " " + r.Method + " " + r.URL.Path + " " + key
+			fp := fingerprint(r, body)
+			now := time.Now()
+			stored, reserved, err := cfg.Store.Reserve(ctx, idempotency.Key{
+				Key:         scoped,
+				Fingerprint: fp,
+				DateCreated: now,
+				DateExpires: now.Add(cfg.TTL),
+			})
+			if err != nil {
+				// The key kept being released by the request holding it
+				// while it was reserved, the client can try again.
+				if errors.Is(err, database.ErrNotFound) {
+					return validate.NewRequestError(errors.New("a request with this idempotency key is in progress"), http.StatusConflict)
+				}
+				return err
+			}
+
+			if !reserved {
+				switch {
+				case stored.Fingerprint != fp:
+					return validate.NewRequestError(errors.New("idempotency key was used for a different request"), http.StatusUnprocessableEntity)
+				case stored.StatusCode == 0:
+					return validate.NewRequestError(errors.New("a request with this idempotency key is in progress"), http.StatusConflict)
+				}
+				return replay(w, stored)
+			}
+
+			rec := recorder{ResponseWriter: w}
+			err = handler(ctx, &rec, r)
+
+			// The client may have gone away while the request was handled,
+			// the key is completed or released regardless. Otherwise it
+			// stays in progress and every retry gets a 409 until it expires.
+			release := func() {
+				rctx, cancel := context.WithTimeout(context.Background(), storeTimeout)
+				defer cancel()
+
+				if rerr := cfg.Store.Release(rctx, scoped); rerr != nil {
+					logger.FromContext(ctx, log).Errorw("idempotency", "status", "releasing key", "ERROR", rerr)
+				}
+			}
+
+			if err != nil || rec.statusCode == 0 || rec.statusCode >= http.StatusInternalServerError {
+				release()
+				return err
+			}
+
+			encoding, body, err := decompress(rec.Header().Get("Content-Encoding"), rec.body.Bytes())
+			if err != nil {
+				logger.FromContext(ctx, log).Errorw("idempotency", "status", "decompressing response", "ERROR", err)
+				release()
+				return nil
+			}
+
+			stored.StatusCode = rec.statusCode
+			stored.ContentType = rec.Header().Get("Content-Type")
+			stored.ContentEncoding = encoding
+			stored.Body = body
+
+			cctx, cancel := context.WithTimeout(context.Background(), storeTimeout)
+			defer cancel()
+
+			if err := cfg.Store.Complete(cctx, stored); err != nil {
+				logger.FromContext(ctx, log).Errorw("idempotency", "status", "completing key", "ERROR", err)
+			}
+
+			return nil
+		}
+	}
+}
+
+// fingerprint identifies a request by its method, path and body.
+func fingerprint(r *http.Request, body []byte) string {
+	h := sha256.New()
+	io.WriteString(h, r.Method+" "+r.URL.Path+"\n")
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// decompress undoes the content encodings the service compresses responses
+// with. Bodies in any other encoding are returned as they are.
+func decompress(encoding string, body []byte) (string, []byte, error) {
+	var r io.Reader
+	switch encoding {
+	case "gzip":
+		zr, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return "", nil, err
+		}
+		r = zr
+	case "br":
+		r = brotli.NewReader(bytes.NewReader(body))
+	default:
+		return encoding, body, nil
+	}
+
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return "", nil, err
+	}
+	return "", b, nil
+}
+
+// replay sends the stored response of a key to the client again.
+func replay(w http.ResponseWriter, key idempotency.Key) error {
+	if key.ContentType != "" {
+		w.Header().Set("Content-Type", key.ContentType)
+	}
+	if key.ContentEncoding != "" {
+		w.Header().Set("Content-Encoding", key.ContentEncoding)
+	}
+	w.Header().Set("Idempotent-Replayed", "true")
+
+	w.WriteHeader(key.StatusCode)
+	if _, err := w.Write(key.Body); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// recorder keeps a copy of the status code and body written to the client.
+type recorder struct {
+	http.ResponseWriter
+	statusCode int
+	body       bytes.Buffer
+}
+
+// WriteHeader implements the http.ResponseWriter interface.
+func (rec *recorder) WriteHeader(statusCode int) {
+	if rec.statusCode == 0 {
+		rec.statusCode = statusCode
+	}
+	rec.ResponseWriter.WriteHeader(statusCode)
+}
+
+// Write implements the http.ResponseWriter interface.
+func (rec *recorder) Write(b []byte) (int, error) {
+	if rec.statusCode == 0 {
+		rec.statusCode = http.StatusOK
+	}
+	rec.body.Write(b)
+	return rec.ResponseWriter.Write(b)
+}
+
+// Flush implements the http.Flusher interface when the wrapped writer
+// supports it.
+func (rec *recorder) Flush() {
+	if f, ok := rec.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap returns the wrapped writer.
+func (rec *recorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
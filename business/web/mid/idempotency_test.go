@@ -0,0 +1,268 @@
+package mid_test
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mohammadhsn/ultimate-service/business/data/store/idempotency"
+	"github.com/mohammadhsn/ultimate-service/business/sys/database"
+	"github.com/mohammadhsn/ultimate-service/business/web/mid"
+	"github.com/mohammadhsn/ultimate-service/foundation/web"
+	"go.uber.org/zap"
+)
+
+// memoryStore keeps idempotency keys in memory.
+type memoryStore struct {
+	mu   sync.Mutex
+	keys map[string]idempotency.Key
+
+	// released makes Reserve fail as if the key was released while it was
+	// being reserved.
+	released bool
+}
+
+func (s *memoryStore) Reserve(ctx context.Context, key idempotency.Key) (idempotency.Key, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.released {
+		return idempotency.Key{}, false, fmt.Errorf("reserving key[%s]: %w", key.Key, database.ErrNotFound)
+	}
+
+	if stored, ok := s.keys[key.Key]; ok && !stored.DateExpires.Before(key.DateCreated) {
+		return stored, false, nil
+	}
+	s.keys[key.Key] = key
+	return key, true, nil
+}
+
+func (s *memoryStore) Complete(ctx context.Context, key idempotency.Key) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys[key.Key] = key
+	return nil
+}
+
+func (s *memoryStore) Release(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.keys[key].StatusCode == 0 {
+		delete(s.keys, key)
+	}
+	return nil
+}
+
+func TestIdempotency(t *testing.T) {
+	t.Log("Given the need to make retried POST requests safe.")
+
+	log := zap.NewNop().Sugar()
+	store := memoryStore{keys: make(map[string]idempotency.Key)}
+
+	app := web.NewApp(make(chan os.Signal, 1), log, mid.Errors(log), mid.Idempotency(log, mid.IdempotencyConfig{
+		Store: &store,
+		TTL:   time.Hour,
+	}))
+
+	var calls int
+	var inner *httptest.ResponseRecorder
+	app.Handle(http.MethodPost, "", "/users", func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		calls++
+		names := make([]string, 100)
+		for i := range names {
+			names[i] = fmt.Sprintf("gopher %d", calls)
+		}
+		return web.Respond(ctx, w, names, http.StatusCreated)
+	})
+	app.Handle(http.MethodPost, "", "/products", func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		calls++
+		return web.Respond(ctx, w, calls, http.StatusCreated)
+	})
+	app.Handle(http.MethodPost, "", "/fail", func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		calls++
+		return errors.New("database is down")
+	})
+	app.Handle(http.MethodPost, "", "/slow", func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		calls++
+
+		// Repeat the request while it is still being processed.
+		inner = httptest.NewRecorder()
+		r2 := httptest.NewRequest(http.MethodPost, "/slow", strings.NewReader(`{}`))
+		r2.Header.Set(mid.IdempotencyHeader, r.Header.Get(mid.IdempotencyHeader))
+		app.ServeHTTP(inner, r2)
+
+		return web.Respond(ctx, w, calls, http.StatusCreated)
+	})
+
+	// The client goes away once the response is written, cancelling the
+	// context of the request.
+	var disconnect context.CancelFunc
+	app.Handle(http.MethodPost, "", "/disconnect", func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		calls++
+		defer disconnect()
+		return web.Respond(ctx, w, calls, http.StatusCreated)
+	})
+
+	post := func(path, key, body, remoteAddr string, header http.Header) *httptest.ResponseRecorder {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		disconnect = cancel
+
+		r := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body)).WithContext(ctx)
+		if remoteAddr != "" {
+			r.RemoteAddr = remoteAddr
+		}
+		for k, vs := range header {
+			r.Header[k] = vs
+		}
+		r.Header.Set(mid.IdempotencyHeader, key)
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, r)
+		return w
+	}
+
+	testID := 0
+	t.Logf("\tTest %d:\tWhen repeating a request.", testID)
+	{
+		calls = 0
+		first := post("/users", "k1", `{"name":"bill"}`, "", nil)
+		second := post("/users", "k1", `{"name":"bill"}`, "", nil)
+
+		if calls != 1 || second.Header().Get("Idempotent-Replayed") != "true" {
+			t.Fatalf("\t%s\tTest %d:\tShould run the request once and replay it : ran %d times.", failed, testID, calls)
+		}
+		t.Logf("\t%s\tTest %d:\tShould run the request once and replay it.", success, testID)
+
+		if second.Code != http.StatusCreated || second.Body.String() != first.Body.String() {
+			t.Fatalf("\t%s\tTest %d:\tShould replay the same response : got %d.", failed, testID, second.Code)
+		}
+		t.Logf("\t%s\tTest %d:\tShould replay the same response.", success, testID)
+	}
+
+	testID++
+	t.Logf("\tTest %d:\tWhen reusing a key for a different request.", testID)
+	{
+		w := post("/users", "k1", `{"name":"ale"}`, "", nil)
+
+		if w.Code != http.StatusUnprocessableEntity {
+			t.Fatalf("\t%s\tTest %d:\tShould receive a %d status code : %d.", failed, testID, http.StatusUnprocessableEntity, w.Code)
+		}
+		t.Logf("\t%s\tTest %d:\tShould receive a %d status code.", success, testID, http.StatusUnprocessableEntity)
+	}
+
+	testID++
+	t.Logf("\tTest %d:\tWhen another client or another route uses the same key.", testID)
+	{
+		calls = 0
+		other := post("/users", "k1", `{"name":"bill"}`, "5.6.7.8:1234", nil)
+		route := post("/products", "k1", `{"name":"bill"}`, "", nil)
+
+		if calls != 2 || other.Header().Get("Idempotent-Replayed") != "" || route.Code != http.StatusCreated {
+			t.Fatalf("\t%s\tTest %d:\tShould keep the keys apart : ran %d times, got %d.", failed, testID, calls, route.Code)
+		}
+		t.Logf("\t%s\tTest %d:\tShould keep the keys apart.", success, testID)
+	}
+
+	testID++
+	t.Logf("\tTest %d:\tWhen the retry does not accept the compression of the response.", testID)
+	{
+		first := post("/users", "k2", `{}`, "", http.Header{"Accept-Encoding": {"gzip"}})
+		if first.Header().Get("Content-Encoding") != "gzip" {
+			t.Fatalf("\t%s\tTest %d:\tShould compress the first response : got %q.", failed, testID, first.Header().Get("Content-Encoding"))
+		}
+		zr, err := gzip.NewReader(first.Body)
+		if err != nil {
+			t.Fatalf("\t%s\tTest %d:\tShould be able to read the first response : %s.", failed, testID, err)
+		}
+		exp, _ := io.ReadAll(zr)
+
+		second := post("/users", "k2", `{}`, "", nil)
+
+		if second.Header().Get("Content-Encoding") != "" || second.Body.String() != string(exp) {
+			t.Fatalf("\t%s\tTest %d:\tShould replay the response uncompressed : got %q.", failed, testID, second.Header().Get("Content-Encoding"))
+		}
+		t.Logf("\t%s\tTest %d:\tShould replay the response uncompressed.", success, testID)
+
+		var names []string
+		if err := json.Unmarshal(second.Body.Bytes(), &names); err != nil {
+			t.Fatalf("\t%s\tTest %d:\tShould replay a readable body : %s.", failed, testID, err)
+		}
+		t.Logf("\t%s\tTest %d:\tShould replay a readable body.", success, testID)
+	}
+
+	testID++
+	t.Logf("\tTest %d:\tWhen the request fails.", testID)
+	{
+		calls = 0
+		first := post("/fail", "k3", `{}`, "", nil)
+		second := post("/fail", "k3", `{}`, "", nil)
+
+		if first.Code != http.StatusInternalServerError || calls != 2 {
+			t.Fatalf("\t%s\tTest %d:\tShould release the key for the retry : got %d, ran %d times.", failed, testID, first.Code, calls)
+		}
+		t.Logf("\t%s\tTest %d:\tShould release the key for the retry.", success, testID)
+
+		if second.Header().Get("Idempotent-Replayed") != "" {
+			t.Fatalf("\t%s\tTest %d:\tShould not replay the failure.", failed, testID)
+		}
+		t.Logf("\t%s\tTest %d:\tShould not replay the failure.", success, testID)
+	}
+
+	testID++
+	t.Logf("\tTest %d:\tWhen repeating a request still in progress.", testID)
+	{
+		calls = 0
+		w := post("/slow", "k4", `{}`, "", nil)
+
+		if w.Code != http.StatusCreated || inner.Code != http.StatusConflict || calls != 1 {
+			t.Fatalf("\t%s\tTest %d:\tShould answer the repeat with a %d : got %d, ran %d times.", failed, testID, http.StatusConflict, inner.Code, calls)
+		}
+		t.Logf("\t%s\tTest %d:\tShould answer the repeat with a %d.", success, testID, http.StatusConflict)
+	}
+
+	testID++
+	t.Logf("\tTest %d:\tWhen the client goes away before the response is stored.", testID)
+	{
+		calls = 0
+		first := post("/disconnect", "k6", `{}`, "", nil)
+		second := post("/disconnect", "k6", `{}`, "", nil)
+
+		if first.Code != http.StatusCreated || second.Code != http.StatusCreated || calls != 1 {
+			t.Fatalf("\t%s\tTest %d:\tShould store the response for the retry : got %d, ran %d times.", failed, testID, second.Code, calls)
+		}
+		t.Logf("\t%s\tTest %d:\tShould store the response for the retry.", success, testID)
+
+		if second.Header().Get("Idempotent-Replayed") != "true" {
+			t.Fatalf("\t%s\tTest %d:\tShould replay the response.", failed, testID)
+		}
+		t.Logf("\t%s\tTest %d:\tShould replay the response.", success, testID)
+	}
+
+	testID++
+	t.Logf("\tTest %d:\tWhen the key is released while it is reserved.", testID)
+	{
+		store.released = true
+		defer func() { store.released = false }()
+
+		w := post("/users", "k5", `{}`, "", nil)
+
+		if w.Code != http.StatusConflict {
+			t.Fatalf("\t%s\tTest %d:\tShould receive a %d status code : %d.", failed, testID, http.StatusConflict, w.Code)
+		}
+		t.Logf("\t%s\tTest %d:\tShould receive a %d status code.", success, testID, http.StatusConflict)
+	}
+}
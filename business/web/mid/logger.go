@@ -2,12 +2,15 @@ package mid
 
 import (
 	"context"
+	"github.com/mohammadhsn/ultimate-service/foundation/logger"
 	"github.com/mohammadhsn/ultimate-service/foundation/web"
 	"go.uber.org/zap"
 	"net/http"
-	"time"
 )
 
+// Logger writes some information about the request to the logs. It attaches
+// a child logger carrying the request fields to the context, so every log
+// line written while handling the request can be correlated.
 func Logger(log *zap.SugaredLogger) web.Middleware {
 	return func(handler web.Handler) web.Handler {
 		return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
@@ -16,28 +19,28 @@ func Logger(log *zap.SugaredLogger) web.Middleware {
 			// to be shutdown gracefully.
 			v, err := web.GetValues(ctx)
 			if err != nil {
-				return err
+				return web.NewShutdownError("web value missing from context")
 			}
 
-			log.Infow(
-				"request started",
+			ctx = logger.WithContext(ctx, logger.FromContext(ctx, log).With(
 				"traceID", v.TraceID,
 				"method", r.Method,
+				"route", v.Route,
 				"path", r.URL.Path,
 				"remoteAddr", r.RemoteAddr,
-			)
+			))
+
+			logger.FromContext(ctx, log).Infow("request started")
 
 			// Call the next handler
 			err = handler(ctx, w, r)
 
-			log.Infow(
+			logger.FromContext(ctx, log).Infow(
 				"request completed",
-				"traceID", v.TraceID,
-				"method", r.Method,
-				"path", r.URL.Path,
-				"remoteAddr", r.RemoteAddr,
 				"statusCode", v.StatusCode,
-				"since", time.Since(v.Now),
+				"bytes", v.Size,
+				"ttfb", v.TimeToFirstByte(),
+				"since", v.Duration(),
 			)
 
 			// Return the error, so it can be handled further up the chain.
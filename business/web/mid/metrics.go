@@ -7,6 +7,9 @@ import (
 	"net/http"
 )
 
+// Metrics updates program counters. It must be the first middleware of the
+// chain, so the error responses are written by the time the response is
+// recorded and errors escaping every other middleware are counted.
 func Metrics() web.Middleware {
 	return func(handler web.Handler) web.Handler {
 		return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
@@ -17,8 +20,20 @@ func Metrics() web.Middleware {
 			metrics.AddRequests(ctx)
 			metrics.AddGoroutines(ctx)
 
-			if err != nil {
-				metrics.AddErrors(ctx)
+			// Errors escaping the chain are answered with a 500 by the App
+			// once this returns. Shutdown requests are not counted as
+			// unhandled, the App handles them by stopping the service.
+			if err != nil && !web.IsShutdown(err) {
+				metrics.AddUnhandledErrors(ctx)
+			}
+
+			v, verr := web.GetValues(ctx)
+			if verr == nil {
+				statusCode := v.StatusCode
+				if err != nil && v.FirstByte.IsZero() {
+					statusCode = http.StatusInternalServerError
+				}
+				metrics.AddResponse(ctx, statusCode, v.Size, v.TimeToFirstByte(), v.Duration())
 			}
 
 			return err
@@ -0,0 +1,261 @@
+package mid
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mohammadhsn/ultimate-service/business/sys/metrics"
+	"github.com/mohammadhsn/ultimate-service/business/sys/validate"
+	"github.com/mohammadhsn/ultimate-service/foundation/web"
+)
+
+// RateLimitConfig contains the settings of a rate limiter. Every client gets
+// a bucket of Burst tokens that refills at Rate tokens per second, each request
+// takes one token.
+type RateLimitConfig struct {
+	Rate  float64
+	Burst int
+
+	// TrustedProxies lists the networks of the proxies allowed to report the
+	// client address through the X-Forwarded-For header.
+	TrustedProxies []*net.IPNet
+
+	// Subject returns the authenticated subject of the request, if any, like
+	// AdminSubject does. It takes precedence over the client address.
+	// Credentials the request carries must only be turned into a subject
+	// once they are verified, otherwise every made up credential gets a
+	// bucket of its own.
+	Subject func(r *http.Request) string
+}
+
+// Validate reports settings the limiter can not work with.
+func (cfg RateLimitConfig) Validate() error {
+	if cfg.Rate > 0 && cfg.Burst <= 0 {
+		return errors.New("the burst of the rate limiter must be positive")
+	}
+	return nil
+}
+
+// RateLimit limits the rate of requests per client using a token bucket.
+// Clients are identified by their authenticated subject or their address.
+// Every middleware value has its own buckets, so different route groups can
+// be given different limits. A zero rate disables the limiter. It panics if
+// the settings are invalid, see Validate.
+func RateLimit(cfg RateLimitConfig) web.Middleware {
+	if err := cfg.Validate(); err != nil {
+		panic(err)
+	}
+
+	l := newLimiter(cfg.Rate, cfg.Burst)
+
+	return func(handler web.Handler) web.Handler {
+		if cfg.Rate <= 0 {
+			return handler
+		}
+
+		return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+			res := l.allow(clientKey(r, cfg.Subject, cfg.TrustedProxies), time.Now())
+
+			h := w.Header()
+			h.Set("RateLimit-Limit", strconv.Itoa(cfg.Burst))
+			h.Set("RateLimit-Remaining", strconv.Itoa(res.remaining))
+			h.Set("RateLimit-Reset", strconv.Itoa(seconds(res.reset)))
+
+			if !res.allowed {
+				metrics.AddRateLimited(ctx)
+				h.Set("Retry-After", strconv.Itoa(seconds(res.retryAfter)))
+				return validate.NewRequestError(errors.New("rate limit exceeded"), http.StatusTooManyRequests)
+			}
+
+			return handler(ctx, w, r)
+		}
+	}
+}
+
+// ParseTrustedProxies parses a list of IP addresses and CIDR networks.
+func ParseTrustedProxies(proxies []string) ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+	for _, p := range proxies {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+
+		if !strings.Contains(p, "/") {
+			ip := net.ParseIP(p)
+			if ip == nil {
+				return nil, errors.New("invalid proxy address " + p)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, n, err := net.ParseCIDR(p)
+		if err != nil {
+			return nil, err
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
+}
+
+// clientKey returns the key identifying the client of the request, its
+// authenticated subject or else its address.
+func clientKey(r *http.Request, subject func(r *http.Request) string, trusted []*net.IPNet) string {
+	if subject != nil {
+		if sub := subject(r); sub != "" {
+			return "sub:" + sub
+		}
+	}
+
+	return "ip:" + clientIP(r, trusted)
+}
+
+// clientIP returns the address of the client. When the request comes from a
+// trusted proxy, the X-Forwarded-For header is walked from the right and the
+// first address that is not a trusted proxy is used.
+func clientIP(r *http.Request, trusted []*net.IPNet) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	if !isTrusted(net.ParseIP(host), trusted) {
+		return host
+	}
+
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		ip := net.ParseIP(hop)
+		if ip == nil {
+			break
+		}
+		host = hop
+		if !isTrusted(ip, trusted) {
+			break
+		}
+	}
+
+	return host
+}
+
+// isTrusted reports whether the address belongs to a trusted network.
+func isTrusted(ip net.IP, trusted []*net.IPNet) bool {
+	if ip == nil {
+		return false
+	}
+	for _, n := range trusted {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// seconds rounds the duration up to whole seconds.
+func seconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+// =============================================================================
+
+// sweepInterval is how often buckets that have refilled are dropped.
+const sweepInterval = time.Minute
+
+// limiter holds a token bucket per client.
+type limiter struct {
+	mu        sync.Mutex
+	rate      float64
+	burst     float64
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+// bucket is the token bucket of a single client.
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// limitResult is the outcome of taking a token.
+type limitResult struct {
+	allowed    bool
+	remaining  int
+	reset      time.Duration
+	retryAfter time.Duration
+}
+
+// newLimiter constructs a limiter for the rate and burst.
+func newLimiter(rate float64, burst int) *limiter {
+	return &limiter{
+		rate:    rate,
+		burst:   float64(burst),
+		buckets: make(map[string]*bucket),
+	}
+}
+
+// allow takes a token from the bucket of the client if there is one.
+func (l *limiter) allow(key string, now time.Time) limitResult {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.sweep(now)
+
+	b, exists := l.buckets[key]
+	if !exists {
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	}
+
+	// Refill the bucket for the time passed since it was last used.
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+
+	var res limitResult
+	if b.tokens >= 1 {
+		b.tokens--
+		res.allowed = true
+	} else {
+		res.retryAfter = l.refill(1 - b.tokens)
+	}
+
+	res.remaining = int(b.tokens)
+	res.reset = l.refill(l.burst - b.tokens)
+
+	return res
+}
+
+// refill returns how long it takes to refill the number of tokens.
+func (l *limiter) refill(tokens float64) time.Duration {
+	if l.rate <= 0 {
+		return 0
+	}
+	return time.Duration(tokens / l.rate * float64(time.Second))
+}
+
+// sweep drops the buckets that are full again, they are identical to new
+// ones. It runs at most once per sweep interval.
+func (l *limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < sweepInterval {
+		return
+	}
+	l.lastSweep = now
+
+	for key, b := range l.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*l.rate >= l.burst {
+			delete(l.buckets, key)
+		}
+	}
+}
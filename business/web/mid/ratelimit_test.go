@@ -0,0 +1,190 @@
+package mid_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/mohammadhsn/ultimate-service/business/web/mid"
+	"github.com/mohammadhsn/ultimate-service/foundation/web"
+	"go.uber.org/zap"
+)
+
+func TestTokenBucket(t *testing.T) {
+	t.Log("Given the need to limit every client to a rate with bursts.")
+
+	allow := mid.NewLimiter(1, 2)
+	now := time.Date(2022, time.March, 1, 10, 0, 0, 0, time.UTC)
+
+	tt := []struct {
+		name       string
+		key        string
+		after      time.Duration
+		allowed    bool
+		remaining  int
+		retryAfter time.Duration
+	}{
+		{"first request", "a", 0, true, 1, 0},
+		{"second request of the burst", "a", 0, true, 0, 0},
+		{"request over the burst", "a", 0, false, 0, time.Second},
+		{"request of another client", "b", 0, true, 1, 0},
+		{"request before a token refilled", "a", 500 * time.Millisecond, false, 0, 500 * time.Millisecond},
+		{"request once a token refilled", "a", time.Second, true, 0, 0},
+		{"request after a long pause", "a", time.Minute, true, 1, 0},
+	}
+
+	for testId, tst := range tt {
+		t.Logf("\tTest %d:\tWhen taking a token for the %s.", testId, tst.name)
+		{
+			allowed, remaining, retryAfter := allow(tst.key, now.Add(tst.after))
+
+			if allowed != tst.allowed || remaining != tst.remaining || retryAfter != tst.retryAfter {
+				t.Fatalf("\t%s\tTest %d:\tShould get %t, %d remaining, retry after %s : got %t, %d, %s.", failed, testId, tst.allowed, tst.remaining, tst.retryAfter, allowed, remaining, retryAfter)
+			}
+			t.Logf("\t%s\tTest %d:\tShould get %t, %d remaining, retry after %s.", success, testId, tst.allowed, tst.remaining, tst.retryAfter)
+		}
+	}
+}
+
+func TestClientKey(t *testing.T) {
+	t.Log("Given the need to tell the clients of the rate limiter apart.")
+
+	trusted, err := mid.ParseTrustedProxies([]string{"10.0.0.0/8", "::1"})
+	if err != nil {
+		t.Fatalf("\t%s\tShould be able to parse the trusted proxies : %s.", failed, err)
+	}
+
+	subject := func(sub string) func(r *http.Request) string {
+		return func(r *http.Request) string { return sub }
+	}
+
+	tt := []struct {
+		name       string
+		subject    func(r *http.Request) string
+		remoteAddr string
+		header     http.Header
+		key        string
+	}{
+		{"authenticated subject", subject("bill"), "1.2.3.4:80", nil, "sub:bill"},
+		{"request without a subject", subject(""), "1.2.3.4:80", nil, "ip:1.2.3.4"},
+		{"unverified API key", nil, "1.2.3.4:80", http.Header{"X-Api-Key": {"made-up"}}, "ip:1.2.3.4"},
+		{"forwarded request from an untrusted peer", nil, "1.2.3.4:80", http.Header{"X-Forwarded-For": {"5.6.7.8"}}, "ip:1.2.3.4"},
+		{"request from a trusted proxy", nil, "10.0.0.1:80", http.Header{"X-Forwarded-For": {"5.6.7.8"}}, "ip:5.6.7.8"},
+		{"request through a chain of trusted proxies", nil, "10.0.0.1:80", http.Header{"X-Forwarded-For": {"6.6.6.6, 5.6.7.8, 10.0.0.2"}}, "ip:5.6.7.8"},
+		{"request split over several headers", nil, "10.0.0.1:80", http.Header{"X-Forwarded-For": {"6.6.6.6", "5.6.7.8"}}, "ip:5.6.7.8"},
+		{"request through trusted proxies only", nil, "10.0.0.1:80", http.Header{"X-Forwarded-For": {"10.0.0.3, 10.0.0.2"}}, "ip:10.0.0.3"},
+		{"request with garbage left of the client", nil, "10.0.0.1:80", http.Header{"X-Forwarded-For": {"garbage, 5.6.7.8"}}, "ip:5.6.7.8"},
+		{"request with garbage from the proxy", nil, "10.0.0.1:80", http.Header{"X-Forwarded-For": {"5.6.7.8, garbage"}}, "ip:10.0.0.1"},
+		{"request from a trusted proxy without the header", nil, "10.0.0.1:80", nil, "ip:10.0.0.1"},
+		{"request from a trusted IPv6 proxy", nil, "[::1]:80", http.Header{"X-Forwarded-For": {"2001:db8::1"}}, "ip:2001:db8::1"},
+	}
+
+	for testId, tst := range tt {
+		t.Logf("\tTest %d:\tWhen handling an %s.", testId, tst.name)
+		{
+			r := httptest.NewRequest(http.MethodGet, "/users", nil)
+			r.RemoteAddr = tst.remoteAddr
+			for k, vs := range tst.header {
+				for _, v := range vs {
+					r.Header.Add(k, v)
+				}
+			}
+
+			if got := mid.ClientKey(r, tst.subject, trusted); got != tst.key {
+				t.Fatalf("\t%s\tTest %d:\tShould use the key %s : got %s.", failed, testId, tst.key, got)
+			}
+			t.Logf("\t%s\tTest %d:\tShould use the key %s.", success, testId, tst.key)
+		}
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	t.Log("Given the need to reject clients going over their rate.")
+
+	testId := 0
+	t.Logf("\tTest %d:\tWhen a client goes over its burst.", testId)
+	{
+		log := zap.NewNop().Sugar()
+		app := web.NewApp(make(chan os.Signal, 1), log, mid.Errors(log), mid.RateLimit(mid.RateLimitConfig{Rate: 1, Burst: 1}))
+		app.Handle(http.MethodGet, "", "/users", func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+			return web.Respond(ctx, w, struct{}{}, http.StatusOK)
+		})
+
+		var codes []int
+		var w *httptest.ResponseRecorder
+		for i := 0; i < 2; i++ {
+			w = httptest.NewRecorder()
+			app.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users", nil))
+			codes = append(codes, w.Code)
+		}
+
+		if codes[0] != http.StatusOK || codes[1] != http.StatusTooManyRequests {
+			t.Fatalf("\t%s\tTest %d:\tShould reject the request over the burst : got %v.", failed, testId, codes)
+		}
+		t.Logf("\t%s\tTest %d:\tShould reject the request over the burst.", success, testId)
+
+		if got := w.Header().Get("Retry-After"); got != "1" {
+			t.Fatalf("\t%s\tTest %d:\tShould tell when to retry : got %q.", failed, testId, got)
+		}
+		t.Logf("\t%s\tTest %d:\tShould tell when to retry.", success, testId)
+	}
+
+	testId++
+	t.Logf("\tTest %d:\tWhen the admin key is used from the address of a limited client.", testId)
+	{
+		log := zap.NewNop().Sugar()
+		app := web.NewApp(make(chan os.Signal, 1), log, mid.Errors(log), mid.RateLimit(mid.RateLimitConfig{
+			Rate:    1,
+			Burst:   1,
+			Subject: mid.AdminSubject("s3cret"),
+		}))
+		app.Handle(http.MethodGet, "", "/users", func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+			return web.Respond(ctx, w, struct{}{}, http.StatusOK)
+		})
+
+		var codes []int
+		for _, authorization := range []string{"", "Bearer guess", "Bearer s3cret", "Bearer s3cret"} {
+			r := httptest.NewRequest(http.MethodGet, "/users", nil)
+			if authorization != "" {
+				r.Header.Set("Authorization", authorization)
+			}
+			w := httptest.NewRecorder()
+			app.ServeHTTP(w, r)
+			codes = append(codes, w.Code)
+		}
+
+		exp := []int{http.StatusOK, http.StatusTooManyRequests, http.StatusOK, http.StatusTooManyRequests}
+		if fmt.Sprint(codes) != fmt.Sprint(exp) {
+			t.Fatalf("\t%s\tTest %d:\tShould give the verified key a bucket of its own : got %v, exp %v.", failed, testId, codes, exp)
+		}
+		t.Logf("\t%s\tTest %d:\tShould give the verified key a bucket of its own.", success, testId)
+	}
+
+	testId++
+	t.Logf("\tTest %d:\tWhen the burst is not positive.", testId)
+	{
+		if err := (mid.RateLimitConfig{Rate: 1, Burst: 0}).Validate(); err == nil {
+			t.Fatalf("\t%s\tTest %d:\tShould reject the settings.", failed, testId)
+		}
+		t.Logf("\t%s\tTest %d:\tShould reject the settings.", success, testId)
+
+		if err := (mid.RateLimitConfig{Rate: 0, Burst: 0}).Validate(); err != nil {
+			t.Fatalf("\t%s\tTest %d:\tShould accept them when the limiter is off : %s.", failed, testId, err)
+		}
+		t.Logf("\t%s\tTest %d:\tShould accept them when the limiter is off.", success, testId)
+
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Fatalf("\t%s\tTest %d:\tShould refuse to construct the limiter.", failed, testId)
+				}
+			}()
+			mid.RateLimit(mid.RateLimitConfig{Rate: 1, Burst: -1})
+		}()
+		t.Logf("\t%s\tTest %d:\tShould refuse to construct the limiter.", success, testId)
+	}
+}
@@ -0,0 +1,178 @@
+// Package cache provides an in-process LRU cache whose entries expire after
+// a time to live.
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// Stats describes the use of a cache since it was constructed.
+type Stats struct {
+	Hits      int64 `json:"hits"`
+	Misses    int64 `json:"misses"`
+	Evictions int64 `json:"evictions"`
+	Size      int   `json:"size"`
+}
+
+// Cache holds up to a fixed number of values, evicting the least recently
+// used one when full. A nil Cache is valid and never holds anything.
+type Cache struct {
+	mu       sync.Mutex
+	capacity int
+	ttl      time.Duration
+	ll       *list.List
+	items    map[string]*list.Element
+	stats    Stats
+
+	// generation changes every time a value is deleted.
+	generation uint64
+}
+
+// entry is a value held by the cache.
+type entry struct {
+	key     string
+	value   interface{}
+	expires time.Time
+}
+
+// New constructs a cache holding up to capacity values for ttl each.
+func New(capacity int, ttl time.Duration) *Cache {
+	return &Cache{
+		capacity: capacity,
+		ttl:      ttl,
+		ll:       list.New(),
+		items:    make(map[string]*list.Element),
+	}
+}
+
+// Get returns the value stored for the key, if it has not expired.
+func (c *Cache) Get(key string) (interface{}, bool) {
+	if c == nil {
+		return nil, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.items[key]
+	if !ok {
+		c.stats.Misses++
+		return nil, false
+	}
+
+	e := el.Value.(*entry)
+	if time.Now().After(e.expires) {
+		c.remove(el)
+		c.stats.Misses++
+		return nil, false
+	}
+
+	c.ll.MoveToFront(el)
+	c.stats.Hits++
+
+	return e.value, true
+}
+
+// Set stores the value for the key, replacing any value stored before.
+func (c *Cache) Set(key string, value interface{}) {
+	if c == nil || c.capacity <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.set(key, value)
+}
+
+// Generation returns the generation of the cache, which changes every time a
+// value is deleted. Read it before loading a value to store with
+// SetIfGeneration.
+func (c *Cache) Generation() uint64 {
+	if c == nil {
+		return 0
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.generation
+}
+
+// SetIfGeneration stores the value for the key like Set, unless a value was
+// deleted since the generation was read. A value loaded before a write and
+// stored after the write deleted the old one is then dropped instead of
+// being served until it expires. It reports whether the value was stored.
+func (c *Cache) SetIfGeneration(key string, value interface{}, generation uint64) bool {
+	if c == nil || c.capacity <= 0 {
+		return false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.generation != generation {
+		return false
+	}
+
+	c.set(key, value)
+	return true
+}
+
+// set stores the value for the key, the caller must hold the lock.
+func (c *Cache) set(key string, value interface{}) {
+	expires := time.Now().Add(c.ttl)
+
+	if el, ok := c.items[key]; ok {
+		e := el.Value.(*entry)
+		e.value, e.expires = value, expires
+		c.ll.MoveToFront(el)
+		return
+	}
+
+	c.items[key] = c.ll.PushFront(&entry{key: key, value: value, expires: expires})
+
+	if c.ll.Len() > c.capacity {
+		c.remove(c.ll.Back())
+		c.stats.Evictions++
+	}
+}
+
+// Delete removes the value stored for the key and moves the cache to a new
+// generation.
+func (c *Cache) Delete(key string) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+
+	if el, ok := c.items[key]; ok {
+		c.remove(el)
+	}
+}
+
+// Stats returns the statistics of the cache.
+func (c *Cache) Stats() Stats {
+	if c == nil {
+		return Stats{}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	s := c.stats
+	s.Size = c.ll.Len()
+	return s
+}
+
+// remove drops the element from the cache.
+func (c *Cache) remove(el *list.Element) {
+	c.ll.Remove(el)
+	delete(c.items, el.Value.(*entry).key)
+}
@@ -0,0 +1,99 @@
+package cache_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mohammadhsn/ultimate-service/foundation/cache"
+)
+
+const (
+	success = "\u2713"
+	failed  = "\u2717"
+)
+
+func TestCache(t *testing.T) {
+	t.Log("Given the need to cache values in process.")
+	{
+		t.Log("\tTest 0:\tWhen the cache is full.")
+		{
+			c := cache.New(2, time.Minute)
+			c.Set("a", 1)
+			c.Set("b", 2)
+			c.Get("a")
+			c.Set("c", 3)
+
+			if _, ok := c.Get("b"); ok {
+				t.Fatalf("\t%s\tTest 0:\tShould evict the least recently used value.", failed)
+			}
+			t.Logf("\t%s\tTest 0:\tShould evict the least recently used value.", success)
+
+			if v, ok := c.Get("a"); !ok || v.(int) != 1 {
+				t.Fatalf("\t%s\tTest 0:\tShould keep the recently used value: got %v.", failed, v)
+			}
+			t.Logf("\t%s\tTest 0:\tShould keep the recently used value.", success)
+
+			s := c.Stats()
+			if s.Hits != 2 || s.Misses != 1 || s.Evictions != 1 || s.Size != 2 {
+				t.Fatalf("\t%s\tTest 0:\tShould count hits, misses and evictions: got %+v.", failed, s)
+			}
+			t.Logf("\t%s\tTest 0:\tShould count hits, misses and evictions.", success)
+		}
+
+		t.Log("\tTest 1:\tWhen a value expired or was deleted.")
+		{
+			c := cache.New(2, time.Millisecond)
+			c.Set("a", 1)
+			time.Sleep(5 * time.Millisecond)
+
+			if _, ok := c.Get("a"); ok {
+				t.Fatalf("\t%s\tTest 1:\tShould not return expired values.", failed)
+			}
+			t.Logf("\t%s\tTest 1:\tShould not return expired values.", success)
+
+			c = cache.New(2, time.Minute)
+			c.Set("a", 1)
+			c.Delete("a")
+
+			if _, ok := c.Get("a"); ok {
+				t.Fatalf("\t%s\tTest 1:\tShould not return deleted values.", failed)
+			}
+			t.Logf("\t%s\tTest 1:\tShould not return deleted values.", success)
+		}
+
+		t.Log("\tTest 2:\tWhen a value is deleted while another one is loaded.")
+		{
+			c := cache.New(2, time.Minute)
+			c.Set("a", 1)
+			gen := c.Generation()
+			c.Delete("a")
+
+			if c.SetIfGeneration("a", 1, gen) {
+				t.Fatalf("\t%s\tTest 2:\tShould drop the value loaded before the delete.", failed)
+			}
+			if _, ok := c.Get("a"); ok {
+				t.Fatalf("\t%s\tTest 2:\tShould not return the value loaded before the delete.", failed)
+			}
+			t.Logf("\t%s\tTest 2:\tShould drop the value loaded before the delete.", success)
+
+			if !c.SetIfGeneration("a", 2, c.Generation()) {
+				t.Fatalf("\t%s\tTest 2:\tShould store the value loaded after the delete.", failed)
+			}
+			if v, ok := c.Get("a"); !ok || v.(int) != 2 {
+				t.Fatalf("\t%s\tTest 2:\tShould return the value loaded after the delete: got %v.", failed, v)
+			}
+			t.Logf("\t%s\tTest 2:\tShould store the value loaded after the delete.", success)
+		}
+
+		t.Log("\tTest 3:\tWhen the cache is nil.")
+		{
+			var c *cache.Cache
+			c.Set("a", 1)
+
+			if _, ok := c.Get("a"); ok {
+				t.Fatalf("\t%s\tTest 3:\tShould never hold values.", failed)
+			}
+			t.Logf("\t%s\tTest 3:\tShould never hold values.", success)
+		}
+	}
+}
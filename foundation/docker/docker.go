@@ -4,116 +4,484 @@ package docker
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
-	"log"
+	"errors"
+	"fmt"
 	"net"
+	"os"
 	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
 	"testing"
+	"time"
 )
 
+// Labels set on every container and network, so the ones left over by
+// crashed test runs can be found and removed. HostLabel tells the hosts and
+// PID namespaces sharing a docker daemon apart, StartLabel the processes
+// reusing the PID of a finished one.
+const (
+	Label      = "ultimate-service.test"
+	HostLabel  = "ultimate-service.test.host"
+	PIDLabel   = "ultimate-service.test.pid"
+	StartLabel = "ultimate-service.test.start"
+)
+
+// DefaultTimeout is how long a container has to become ready when its
+// configuration does not say otherwise.
+const DefaultTimeout = 30 * time.Second
+
 type Container struct {
 	Id   string
+	Name string
 	Host string // IP:Port
 }
 
-// StartContainer starts the specified container for running tests.
-func StartContainer(t *testing.T, image string, port string, args ...string) *Container {
-	defaultArgs := []string{"run", "-P", "-d"}
-	defaultArgs = append(defaultArgs, args...)
-	defaultArgs = append(defaultArgs, image)
+// Config describes a container to start.
+type Config struct {
+	Image string
+	Port  string
+	Args  []string
 
-	cmd := exec.Command("docker", defaultArgs...)
-	var out bytes.Buffer
-	cmd.Stdout = &out
-	if err := cmd.Run(); err != nil {
-		t.Fatalf("could not start container %s: %v", image, err)
-	}
+	// Name is the name other containers of a group reach this one by.
+	Name string
 
-	id := out.String()[:12]
+	// HealthCmd is run inside the container to tell whether it is ready.
+	// Without it the container is ready once its port accepts connections.
+	HealthCmd string
 
-	cmd = exec.Command("docker", "container", "inspect", id)
-	out.Reset()
-	cmd.Stdout = &out
-	if err := cmd.Run(); err != nil {
-		t.Fatalf("could not inspect container %s: %v", id, err)
+	// Timeout is how long the container has to become ready.
+	Timeout time.Duration
+}
+
+// StartContainer starts the specified container for running tests and waits
+// for its port to accept connections.
+func StartContainer(image string, port string, args ...string) (*Container, error) {
+	return Start(Config{
+		Image: image,
+		Port:  port,
+		Args:  args,
+	})
+}
+
+// Start starts the container and waits until it is ready. The logs of the
+// container are part of the error when it never becomes ready.
+func Start(cfg Config) (*Container, error) {
+	return start(cfg, "")
+}
+
+// start starts the container, attached to the network when not empty.
+func start(cfg Config, network string) (*Container, error) {
+	args := append([]string{"run", "-P", "-d"}, labelArgs()...)
+	if network != "" {
+		args = append(args, "--network", network)
+		if cfg.Name != "" {
+			args = append(args, "--network-alias", cfg.Name)
+		}
 	}
+	if cfg.HealthCmd != "" {
+		args = append(args, "--health-cmd", cfg.HealthCmd, "--health-interval", "1s")
+	}
+	args = append(args, cfg.Args...)
+	args = append(args, cfg.Image)
 
-	var doc []map[string]interface{}
-	if err := json.Unmarshal(out.Bytes(), &doc); err != nil {
-		t.Fatalf("could not decode json: %v", err)
+	out, err := docker(args...)
+	if err != nil {
+		return nil, fmt.Errorf("could not start container %s: %w", cfg.Image, err)
 	}
 
-	ip, randPort := extractIpPort(t, doc, port)
+	if len(out) < 12 {
+		return nil, fmt.Errorf("could not start container %s: unexpected id %q", cfg.Image, out)
+	}
 
 	c := Container{
-		Id:   id,
-		Host: net.JoinHostPort(ip, randPort),
+		Id:   out[:12],
+		Name: cfg.Name,
+	}
+
+	host, err := hostPort(c.Id, cfg.Port)
+	if err != nil {
+		StopContainer(c.Id)
+		return nil, err
 	}
+	c.Host = host
 
-	t.Logf("Image:       %s", image)
-	t.Logf("ContainerId: %s", c.Id)
-	t.Logf("Host:        %s", c.Host)
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+
+	if err := wait(&c, cfg.HealthCmd != "", timeout); err != nil {
+		logs := DumpContainerLogs(c.Id)
+		StopContainer(c.Id)
+		return nil, fmt.Errorf("%w\nLogs for %s\n%s", err, c.Id, logs)
+	}
 
-	return &c
+	return &c, nil
 }
 
 // StopContainer stops and removes the specified container.
-func StopContainer(t *testing.T, id string) {
-	if err := exec.Command("docker", "container", "stop", id).Run(); err != nil {
-		log.Fatalf("could not stop container: %v", err)
+func StopContainer(id string) error {
+	if _, err := docker("container", "rm", "--force", "--volumes", id); err != nil {
+		return fmt.Errorf("could not remove container: %w", err)
 	}
-	t.Log("Stopped:", id)
 
-	if err := exec.Command("docker", "container", "rm", id, "-v").Run(); err != nil {
-		t.Fatalf("could not remove container: %v", err)
-	}
-	t.Log("Removed:", id)
+	return nil
 }
 
-// DumpContainerLogs outputs logs from the running docker container.
-func DumpContainerLogs(t *testing.T, id string) {
+// DumpContainerLogs returns the logs of the docker container.
+func DumpContainerLogs(id string) []byte {
 	out, err := exec.Command("docker", "container", "logs", id).CombinedOutput()
 	if err != nil {
-		t.Fatalf("could not log container: %v", err)
+		return []byte(fmt.Sprintf("could not log container: %v", err))
+	}
+	return out
+}
+
+// DumpLogsOnFailure logs the logs of the containers once the test ends, if
+// it failed.
+func DumpLogsOnFailure(t *testing.T, cs ...*Container) {
+	t.Cleanup(func() {
+		if !t.Failed() {
+			return
+		}
+		for _, c := range cs {
+			if c != nil {
+				t.Logf("Logs for %s\n%s", c.Id, DumpContainerLogs(c.Id))
+			}
+		}
+	})
+}
+
+// =============================================================================
+
+// Group is a set of containers started on a network of their own, so they
+// can reach each other by name.
+type Group struct {
+	Network    string
+	Containers []*Container
+}
+
+// StartGroup creates a network and starts the containers on it in order, each
+// one ready before the next starts, so later containers can depend on
+// earlier ones. Everything started is removed when one of them fails.
+func StartGroup(name string, cfgs ...Config) (*Group, error) {
+	network := fmt.Sprintf("%s-%d-%d", name, os.Getpid(), time.Now().UnixNano())
+
+	args := append([]string{"network", "create"}, labelArgs()...)
+	if _, err := docker(append(args, network)...); err != nil {
+		return nil, fmt.Errorf("could not create network %s: %w", network, err)
 	}
-	t.Logf("Logs for %s\n%s", id, out)
+
+	g := Group{Network: network}
+
+	for _, cfg := range cfgs {
+		c, err := start(cfg, network)
+		if err != nil {
+			g.Stop()
+			return nil, err
+		}
+		g.Containers = append(g.Containers, c)
+	}
+
+	return &g, nil
 }
 
-func extractIpPort(t *testing.T, doc []map[string]interface{}, port string) (string, string) {
-	nw, exists := doc[0]["NetworkSettings"]
-	if !exists {
-		t.Fatal("could not get network settings")
+// Container returns the container of the group with the name.
+func (g *Group) Container(name string) *Container {
+	for _, c := range g.Containers {
+		if c.Name == name {
+			return c
+		}
 	}
+	return nil
+}
 
-	ports, exists := nw.(map[string]interface{})["Ports"]
-	if !exists {
-		t.Fatal("could not get network ports settings")
+// Stop removes the containers of the group, in reverse order, and its
+// network.
+func (g *Group) Stop() error {
+	var errs []string
+	for i := len(g.Containers) - 1; i >= 0; i-- {
+		if err := StopContainer(g.Containers[i].Id); err != nil {
+			errs = append(errs, err.Error())
+		}
 	}
 
-	tcp, exists := ports.(map[string]interface{})[port+"/tcp"]
-	if !exists {
-		t.Fatal("could not get network ports/tcp settings")
+	if _, err := docker("network", "rm", g.Network); err != nil {
+		errs = append(errs, fmt.Sprintf("could not remove network %s: %v", g.Network, err))
 	}
 
-	list, exists := tcp.([]interface{})
-	if !exists {
-		t.Fatal("could not get network ports/tcp list settings")
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "; "))
 	}
+	return nil
+}
 
-	var hostIp, hostPort string
+// =============================================================================
 
-	for _, l := range list {
-		data, exists := l.(map[string]interface{})
-		if !exists {
-			t.Fatal("could not get network ports/tcp list data")
+// RemoveStale removes the containers and networks started on this host by
+// test processes that are no longer running, which happens when a test run
+// crashes. The ones of other hosts, users or PID namespaces sharing the docker
+// daemon are left alone, whether their processes run cannot be told from here.
+func RemoveStale() error {
+	out, err := docker("container", "ls", "--all", "--filter", "label="+Label, "--format", listFormat)
+	if err != nil {
+		return fmt.Errorf("could not list containers: %w", err)
+	}
+	for _, id := range stale(out, self(), alive) {
+		if err := StopContainer(id); err != nil {
+			return err
 		}
+	}
 
-		hostIp = data["HostIp"].(string)
-		if hostIp != "::" {
-			hostIp = data["HostIp"].(string)
+	out, err = docker("network", "ls", "--filter", "label="+Label, "--format", listFormat)
+	if err != nil {
+		return fmt.Errorf("could not list networks: %w", err)
+	}
+	for _, id := range stale(out, self(), alive) {
+		if _, err := docker("network", "rm", id); err != nil {
+			return fmt.Errorf("could not remove network %s: %w", id, err)
 		}
+	}
+
+	return nil
+}
+
+// listFormat lists containers and networks as the lines stale reads.
+const listFormat = `{{.ID}}|{{.Label "` + HostLabel + `"}}|{{.Label "` + PIDLabel + `"}}|{{.Label "` + StartLabel + `"}}`
+
+// owner identifies the test process that started a container or network.
+type owner struct {
+	host  string
+	pid   int
+	start string
+}
+
+var (
+	selfOnce  sync.Once
+	selfOwner owner
+)
 
-		hostPort = data["HostPort"].(string)
+// self returns the owner of the containers and networks this process starts.
+func self() owner {
+	selfOnce.Do(func() {
+		pid := os.Getpid()
+		start, _ := processStart(pid)
+		selfOwner = owner{host: hostID(), pid: pid, start: start}
+	})
+	return selfOwner
+}
+
+// labelArgs returns the docker arguments labelling a container or network as
+// started by this process.
+func labelArgs() []string {
+	o := self()
+	return []string{
+		"--label", Label + "=true",
+		"--label", HostLabel + "=" + o.host,
+		"--label", PIDLabel + "=" + strconv.Itoa(o.pid),
+		"--label", StartLabel + "=" + o.start,
+	}
+}
+
+// stale returns the IDs of the "<id>|<host>|<pid>|<start>" lines started on
+// the host of self by a process that is not alive anymore. Lines it cannot
+// read, like the ones of older runs without a host, are never stale.
+func stale(list string, self owner, alive func(pid int, start string) bool) []string {
+	var ids []string
+	for _, line := range strings.Split(list, "\n") {
+		fields := strings.Split(strings.TrimSpace(line), "|")
+		if len(fields) != 4 || fields[0] == "" || fields[1] == "" || fields[1] != self.host {
+			continue
+		}
+		pid, err := strconv.Atoi(fields[2])
+		if err != nil || pid <= 0 {
+			continue
+		}
+		if !alive(pid, fields[3]) {
+			ids = append(ids, fields[0])
+		}
 	}
-	return hostIp, hostPort
+	return ids
+}
+
+// alive reports whether the process is still running. When the start time of
+// the process is known, a process with another start time reused the PID and
+// the original one is gone.
+func alive(pid int, start string) bool {
+	st, err := processStart(pid)
+	switch {
+	case err == nil:
+		return start == "" || st == start
+	case errors.Is(err, os.ErrNotExist) && procfs():
+		return false
+	}
+
+	// Without /proc, a signal tells whether the process runs. A process of
+	// another user cannot be signalled but does run.
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	err = p.Signal(syscall.Signal(0))
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
+
+// processStart returns the start time of the process, in clock ticks since
+// boot, as found in /proc.
+func processStart(pid int) (string, error) {
+	b, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return "", err
+	}
+
+	// The command name may hold spaces, the fields after it do not. The
+	// start time is the 22nd field, the 20th after the name.
+	stat := string(b)
+	i := strings.LastIndexByte(stat, ')')
+	if i < 0 {
+		return "", fmt.Errorf("unexpected stat %q", stat)
+	}
+	fields := strings.Fields(stat[i+1:])
+	if len(fields) < 20 {
+		return "", fmt.Errorf("unexpected stat %q", stat)
+	}
+	return fields[19], nil
+}
+
+// procfs reports whether processes can be looked up in /proc.
+func procfs() bool {
+	_, err := os.Stat("/proc/self/stat")
+	return err == nil
+}
+
+// hostID identifies the host and PID namespace of this process, and the boot
+// of the host, since PIDs only mean something within them.
+func hostID() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	parts := []string{host}
+
+	if ns, err := os.Readlink("/proc/self/ns/pid"); err == nil {
+		parts = append(parts, ns)
+	}
+	if b, err := os.ReadFile("/proc/sys/kernel/random/boot_id"); err == nil {
+		parts = append(parts, strings.TrimSpace(string(b)))
+	}
+
+	return strings.Join(parts, ",")
+}
+
+// =============================================================================
+
+// wait polls the container until it is ready: healthy when it has a health
+// check, accepting connections on its port otherwise.
+func wait(c *Container, health bool, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	for {
+		state, err := inspectState(c.Id)
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case state.Status == "exited" || state.Status == "dead":
+			return fmt.Errorf("container %s %s with code %d", c.Id, state.Status, state.ExitCode)
+
+		case health && state.Health != nil && state.Health.Status == "healthy":
+			return nil
+
+		case !health:
+			conn, err := net.DialTimeout("tcp", c.Host, time.Second)
+			if err == nil {
+				conn.Close()
+				return nil
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("container %s not ready after %v", c.Id, timeout)
+		case <-time.After(250 * time.Millisecond):
+		}
+	}
+}
+
+// state is the part of the container state wait looks at.
+type state struct {
+	Status   string
+	ExitCode int
+	Health   *struct {
+		Status string
+	}
+}
+
+// inspectState returns the state of the container.
+func inspectState(id string) (state, error) {
+	out, err := docker("container", "inspect", "--format", "{{json .State}}", id)
+	if err != nil {
+		return state{}, fmt.Errorf("could not inspect container %s: %w", id, err)
+	}
+
+	var s state
+	if err := json.Unmarshal([]byte(out), &s); err != nil {
+		return state{}, fmt.Errorf("could not decode json: %w", err)
+	}
+	return s, nil
+}
+
+// hostPort returns the address the port of the container is published on.
+func hostPort(id string, port string) (string, error) {
+	out, err := docker("container", "inspect", "--format", "{{json .NetworkSettings.Ports}}", id)
+	if err != nil {
+		return "", fmt.Errorf("could not inspect container %s: %w", id, err)
+	}
+
+	var ports map[string][]struct {
+		HostIp   string
+		HostPort string
+	}
+	if err := json.Unmarshal([]byte(out), &ports); err != nil {
+		return "", fmt.Errorf("could not decode json: %w", err)
+	}
+
+	bindings := ports[port+"/tcp"]
+	if len(bindings) == 0 {
+		return "", fmt.Errorf("could not get network ports/tcp settings for %s", port)
+	}
+
+	// Prefer the IPv4 binding.
+	b := bindings[0]
+	for _, binding := range bindings {
+		if !strings.Contains(binding.HostIp, ":") {
+			b = binding
+			break
+		}
+	}
+
+	return net.JoinHostPort(b.HostIp, b.HostPort), nil
+}
+
+// docker runs the docker command and returns its trimmed output.
+func docker(args ...string) (string, error) {
+	cmd := exec.Command("docker", args...)
+
+	var out, stderr bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("%w: %s", err, msg)
+		}
+		return "", err
+	}
+
+	return strings.TrimSpace(out.String()), nil
 }
@@ -0,0 +1,103 @@
+package docker_test
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mohammadhsn/ultimate-service/foundation/docker"
+)
+
+const (
+	success = "\u2713"
+	failed  = "\u2717"
+)
+
+func TestStale(t *testing.T) {
+	t.Log("Given the need to only remove the resources of crashed test runs of this host.")
+
+	// Process 10 runs since tick 500, process 20 is gone.
+	alive := func(pid int, start string) bool {
+		return pid == 10 && (start == "" || start == "500")
+	}
+
+	tt := []struct {
+		name string
+		list string
+		exp  []string
+	}{
+		{"a running process", "c1|host-a|10|500", nil},
+		{"a process that is gone", "c1|host-a|20|500", []string{"c1"}},
+		{"a PID reused by another process", "c1|host-a|10|400", []string{"c1"}},
+		{"a process without a start time", "c1|host-a|10|", nil},
+		{"another host", "c1|host-b|20|500", nil},
+		{"a run without a host", "c1||20|500", nil},
+		{"an unreadable PID", "c1|host-a|x|500\nc2|host-a|0|500", nil},
+		{"a line of another format", "c1 20", nil},
+		{"several lines", "c1|host-a|10|500\nc2|host-a|20|500\n\nc3|host-b|20|500\nc4|host-a|10|1", []string{"c2", "c4"}},
+	}
+
+	for testId, tst := range tt {
+		t.Logf("\tTest %d:\tWhen listing %s.", testId, tst.name)
+		{
+			got := docker.Stale(tst.list, "host-a", alive)
+			if !reflect.DeepEqual(got, tst.exp) {
+				t.Fatalf("\t%s\tTest %d:\tShould find %v stale : %v.", failed, testId, tst.exp, got)
+			}
+			t.Logf("\t%s\tTest %d:\tShould find %v stale.", success, testId, tst.exp)
+		}
+	}
+}
+
+func TestAlive(t *testing.T) {
+	t.Log("Given the need to tell whether the process of a test run still runs.")
+	{
+		host, start := docker.Self()
+
+		testId := 0
+		t.Logf("\tTest %d:\tWhen checking this process.", testId)
+		{
+			if !docker.Alive(os.Getpid(), start) {
+				t.Fatalf("\t%s\tTest %d:\tShould be alive.", failed, testId)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be alive.", success, testId)
+
+			if host == "" || strings.Contains(host, "|") {
+				t.Fatalf("\t%s\tTest %d:\tShould have a host usable as a label : %q.", failed, testId, host)
+			}
+			t.Logf("\t%s\tTest %d:\tShould have a host usable as a label.", success, testId)
+		}
+
+		testId++
+		t.Logf("\tTest %d:\tWhen checking this PID with another start time.", testId)
+		{
+			switch {
+			case start == "":
+				t.Logf("\t%s\tTest %d:\tShould not know start times without /proc.", success, testId)
+			case docker.Alive(os.Getpid(), start+"0"):
+				t.Fatalf("\t%s\tTest %d:\tShould not be alive.", failed, testId)
+			default:
+				t.Logf("\t%s\tTest %d:\tShould not be alive.", success, testId)
+			}
+		}
+
+		testId++
+		t.Logf("\tTest %d:\tWhen checking the init process.", testId)
+		{
+			if !docker.Alive(1, "") {
+				t.Fatalf("\t%s\tTest %d:\tShould be alive, even if owned by another user.", failed, testId)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be alive, even if owned by another user.", success, testId)
+		}
+
+		testId++
+		t.Logf("\tTest %d:\tWhen checking a process that does not exist.", testId)
+		{
+			if docker.Alive(1<<22+1, "") {
+				t.Fatalf("\t%s\tTest %d:\tShould not be alive.", failed, testId)
+			}
+			t.Logf("\t%s\tTest %d:\tShould not be alive.", success, testId)
+		}
+	}
+}
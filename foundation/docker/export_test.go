@@ -0,0 +1,17 @@
+package docker
+
+// Alive exports alive for the tests of the package.
+var Alive = alive
+
+// Stale exports stale for the tests of the package, for resources started on
+// the host.
+func Stale(list string, host string, alive func(pid int, start string) bool) []string {
+	return stale(list, owner{host: host}, alive)
+}
+
+// Self returns the host and start time labelled on the resources this
+// process starts.
+func Self() (host string, start string) {
+	o := self()
+	return o.host, o.start
+}
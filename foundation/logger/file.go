@@ -0,0 +1,112 @@
+package logger
+
+import (
+	"fmt"
+	"os"
+	"sync"
+)
+
+// rotatingFile is a log sink that writes to a local file and rotates it once
+// it grows past the maximum size. Rotated files are renamed with a numeric
+// suffix, path.1 being the most recent one.
+type rotatingFile struct {
+	mu         sync.Mutex
+	path       string
+	maxSize    int64
+	maxBackups int
+	file       *os.File
+	size       int64
+}
+
+// newRotatingFile opens or creates the file at path for appending. A maxSize
+// of zero or less disables rotation.
+func newRotatingFile(path string, maxSizeMB int, maxBackups int) (*rotatingFile, error) {
+	rf := rotatingFile{
+		path:       path,
+		maxSize:    int64(maxSizeMB) * 1024 * 1024,
+		maxBackups: maxBackups,
+	}
+
+	if err := rf.open(); err != nil {
+		return nil, err
+	}
+
+	return &rf, nil
+}
+
+// Write implements the io.Writer interface.
+func (rf *rotatingFile) Write(p []byte) (int, error) {
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+
+	if rf.maxSize > 0 && rf.size > 0 && rf.size+int64(len(p)) > rf.maxSize {
+		if err := rf.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := rf.file.Write(p)
+	rf.size += int64(n)
+	return n, err
+}
+
+// Sync implements the zapcore.WriteSyncer interface.
+func (rf *rotatingFile) Sync() error {
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+
+	return rf.file.Sync()
+}
+
+// open opens the file for appending and records its current size.
+func (rf *rotatingFile) open() error {
+	f, err := os.OpenFile(rf.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	rf.file = f
+	rf.size = info.Size()
+	return nil
+}
+
+// rotate closes the current file, shifts the backups and opens a new file.
+// The oldest backup is removed once there are more than maxBackups.
+func (rf *rotatingFile) rotate() error {
+	if err := rf.file.Close(); err != nil {
+		return fmt.Errorf("closing: %w", err)
+	}
+
+	if rf.maxBackups <= 0 {
+		if err := os.Remove(rf.path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("removing: %w", err)
+		}
+		return rf.open()
+	}
+
+	if err := os.Remove(backupName(rf.path, rf.maxBackups)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("removing oldest backup: %w", err)
+	}
+	for i := rf.maxBackups - 1; i >= 1; i-- {
+		if err := os.Rename(backupName(rf.path, i), backupName(rf.path, i+1)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("shifting backup: %w", err)
+		}
+	}
+
+	if err := os.Rename(rf.path, backupName(rf.path, 1)); err != nil {
+		return fmt.Errorf("renaming: %w", err)
+	}
+
+	return rf.open()
+}
+
+// backupName returns the name of the n-th backup of path.
+func backupName(path string, n int) string {
+	return fmt.Sprintf("%s.%d", path, n)
+}
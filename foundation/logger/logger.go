@@ -3,26 +3,202 @@
 package logger
 
 import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sync/atomic"
+	"time"
+
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 )
 
-// New constructs a Sugared Logger that writes to stdout and
-// provides human-readable timestamps.
-func New(service string) (*zap.SugaredLogger, error) {
-	config := zap.NewProductionConfig()
-	config.OutputPaths = []string{"stdout"}
-	config.EncoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
-	config.DisableStacktrace = true
-	config.InitialFields = map[string]interface{}{
-		"service": service,
+// Config contains the settings for constructing a logger. Only the service
+// name is required, everything else is optional.
+type Config struct {
+	Service string
+
+	// Output is where the main sink writes, stdout when nil.
+	Output io.Writer
+
+	// Level controls the level of the stdout and file sinks. It can be
+	// changed at runtime. A zero value logs at info.
+	Level zap.AtomicLevel
+
+	// SampleInitial and SampleThereafter configure sampling of repeated
+	// messages: per SampleTick, the first SampleInitial entries with the same
+	// level and message are logged, then every SampleThereafter-th one.
+	// A zero SampleInitial disables sampling.
+	SampleInitial    int
+	SampleThereafter int
+	SampleTick       time.Duration
+
+	// Redact lists the field keys whose values are replaced entirely, Mask
+	// the ones whose values are partially hidden. Keys are case-insensitive.
+	Redact []string
+	Mask   []string
+
+	// FilePath enables an extra sink that writes to a local file, rotated
+	// once it grows past FileMaxSize megabytes. FileMaxBackups rotated files
+	// are kept.
+	FilePath       string
+	FileMaxSize    int
+	FileMaxBackups int
+
+	// ErrorsToStderr enables an extra sink writing error level entries and
+	// above to stderr.
+	ErrorsToStderr bool
+}
+
+// New constructs a Sugared Logger that writes to stdout, or the configured
+// output, and provides human-readable timestamps.
+func New(cfg Config) (*zap.SugaredLogger, error) {
+	if cfg.Level == (zap.AtomicLevel{}) {
+		cfg.Level = zap.NewAtomicLevel()
 	}
 
-	log, err := config.Build()
+	encoderConfig := zap.NewProductionEncoderConfig()
+	encoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
+	encoder := zapcore.NewJSONEncoder(encoderConfig)
+
+	rules := newRedactRules(cfg.Redact, cfg.Mask)
+
+	out := zapcore.Lock(os.Stdout)
+	if cfg.Output != nil {
+		out = zapcore.Lock(zapcore.AddSync(cfg.Output))
+	}
+
+	cores := []zapcore.Core{
+		redact(zapcore.NewCore(encoder, out, cfg.Level), rules),
+	}
 
-	if err != nil {
-		return nil, err
+	if cfg.FilePath != "" {
+		f, err := newRotatingFile(cfg.FilePath, cfg.FileMaxSize, cfg.FileMaxBackups)
+		if err != nil {
+			return nil, fmt.Errorf("opening log file: %w", err)
+		}
+		cores = append(cores, redact(zapcore.NewCore(encoder.Clone(), f, cfg.Level), rules))
 	}
 
+	if cfg.ErrorsToStderr {
+		core := redact(zapcore.NewCore(encoder.Clone(), zapcore.Lock(os.Stderr), zapcore.ErrorLevel), rules)
+		cores = append(cores, levelFilter{core, zapcore.ErrorLevel})
+	}
+
+	core := zapcore.NewTee(cores...)
+
+	if cfg.SampleInitial > 0 {
+		tick := cfg.SampleTick
+		if tick <= 0 {
+			tick = time.Second
+		}
+		core = zapcore.NewSamplerWithOptions(core, tick, cfg.SampleInitial, cfg.SampleThereafter)
+	}
+
+	log := zap.New(core,
+		zap.AddCaller(),
+		zap.ErrorOutput(zapcore.Lock(os.Stderr)),
+		zap.Fields(zap.String("service", cfg.Service)),
+	)
+
 	return log.Sugar(), nil
 }
+
+// levelFilter drops entries below the level on write. Sinks that should
+// never see low level entries need it, since a forced debug logger writes
+// through every sink.
+type levelFilter struct {
+	zapcore.Core
+	level zapcore.Level
+}
+
+// With implements the zapcore.Core interface.
+func (c levelFilter) With(fields []zapcore.Field) zapcore.Core {
+	return levelFilter{c.Core.With(fields), c.level}
+}
+
+// Write implements the zapcore.Core interface.
+func (c levelFilter) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	if ent.Level < c.level {
+		return nil
+	}
+	return c.Core.Write(ent, fields)
+}
+
+// ForceDebug returns a copy of the logger that writes debug entries no matter
+// what level the logger was configured with.
+func ForceDebug(log *zap.SugaredLogger) *zap.SugaredLogger {
+	return log.Desugar().WithOptions(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+		return debugCore{core}
+	})).Sugar()
+}
+
+// debugCore enables every level on the wrapped core.
+type debugCore struct {
+	zapcore.Core
+}
+
+// Enabled implements the zapcore.LevelEnabler interface.
+func (c debugCore) Enabled(zapcore.Level) bool {
+	return true
+}
+
+// With implements the zapcore.Core interface.
+func (c debugCore) With(fields []zapcore.Field) zapcore.Core {
+	return debugCore{c.Core.With(fields)}
+}
+
+// Check implements the zapcore.Core interface.
+func (c debugCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	return ce.AddCore(ent, c)
+}
+
+// =============================================================================
+
+// ctxKey represents the type of value for the context key.
+type ctxKey int
+
+// key is how the logger is stored/retrieved.
+const key ctxKey = 1
+
+// holder carries the request logger. It is stored in the context as a pointer
+// so fields added further down the call chain show up in log lines written by
+// callers higher up, like the request completed line.
+type holder struct {
+	log atomic.Value
+}
+
+// WithContext returns a copy of ctx that carries the logger.
+func WithContext(ctx context.Context, log *zap.SugaredLogger) context.Context {
+	var h holder
+	h.log.Store(log)
+	return context.WithValue(ctx, key, &h)
+}
+
+// FromContext returns the logger carried by the context. If the context has
+// none, the fallback logger is returned.
+func FromContext(ctx context.Context, fallback *zap.SugaredLogger) *zap.SugaredLogger {
+	if h, ok := ctx.Value(key).(*holder); ok {
+		return h.log.Load().(*zap.SugaredLogger)
+	}
+	return fallback
+}
+
+// With adds the key/value pairs to the logger carried by the context, so
+// every later log line of the request includes them. It does nothing if the
+// context carries no logger.
+func With(ctx context.Context, args ...interface{}) {
+	if h, ok := ctx.Value(key).(*holder); ok {
+		h.log.Store(h.log.Load().(*zap.SugaredLogger).With(args...))
+	}
+}
+
+// EnableDebug switches the logger carried by the context to write debug
+// entries for the rest of the request. It does nothing if the context
+// carries no logger.
+func EnableDebug(ctx context.Context) {
+	if h, ok := ctx.Value(key).(*holder); ok {
+		h.log.Store(ForceDebug(h.log.Load().(*zap.SugaredLogger)).With("debugRequest", true))
+	}
+}
@@ -0,0 +1,227 @@
+package logger_test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/mohammadhsn/ultimate-service/foundation/logger"
+	"go.uber.org/zap"
+)
+
+const (
+	success = "\u2713"
+	failed  = "\u2717"
+)
+
+// entries decodes the JSON log lines written to the buffer.
+func entries(t *testing.T, buf *bytes.Buffer) []map[string]interface{} {
+	t.Helper()
+
+	var es []map[string]interface{}
+	sc := bufio.NewScanner(buf)
+	for sc.Scan() {
+		e := make(map[string]interface{})
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			t.Fatalf("\t%s\tShould write JSON lines: %s: %q", failed, err, sc.Text())
+		}
+		es = append(es, e)
+	}
+	return es
+}
+
+func TestRedaction(t *testing.T) {
+	var buf bytes.Buffer
+	log, err := logger.New(logger.Config{
+		Service: "TEST",
+		Output:  &buf,
+		Redact:  []string{"password", "password_confirm"},
+		Mask:    []string{"email", "name"},
+	})
+	if err != nil {
+		t.Fatalf("\t%s\tShould be able to construct the logger: %s", failed, err)
+	}
+
+	type newUser struct {
+		Name            string `json:"name"`
+		Email           string `json:"email"`
+		Password        string `json:"password"`
+		PasswordConfirm string `json:"password_confirm"`
+	}
+
+	t.Log("Given the need to keep secrets out of the logs.")
+	{
+		t.Log("\tTest 0:\tWhen logging secrets as top level fields.")
+		{
+			log.Infow("login", "password", "gophers", "email", "bill@ardanlabs.com", "name", "Élodie")
+			log.Sync()
+
+			e := entries(t, &buf)[0]
+			if e["password"] != "[REDACTED]" {
+				t.Fatalf("\t%s\tTest 0:\tShould redact the password: got %v.", failed, e["password"])
+			}
+			t.Logf("\t%s\tTest 0:\tShould redact the password.", success)
+
+			if e["email"] != "b***@ardanlabs.com" {
+				t.Fatalf("\t%s\tTest 0:\tShould mask the email: got %v.", failed, e["email"])
+			}
+			t.Logf("\t%s\tTest 0:\tShould mask the email.", success)
+
+			name, _ := e["name"].(string)
+			if name != "É***" || !utf8.ValidString(name) {
+				t.Fatalf("\t%s\tTest 0:\tShould keep the whole first character: got %q.", failed, name)
+			}
+			t.Logf("\t%s\tTest 0:\tShould keep the whole first character.", success)
+		}
+
+		t.Log("\tTest 1:\tWhen logging secrets nested in values.")
+		{
+			nu := newUser{Name: "Bill", Email: "bill@ardanlabs.com", Password: "gophers", PasswordConfirm: "gophers"}
+			log.Infow("create", "user", nu, "batch", []newUser{nu}, "count", 1)
+			log.Sync()
+
+			out := buf.String()
+			if strings.Contains(out, "gophers") {
+				t.Fatalf("\t%s\tTest 1:\tShould redact nested secrets: got %s.", failed, out)
+			}
+			t.Logf("\t%s\tTest 1:\tShould redact nested secrets.", success)
+
+			e := entries(t, &buf)[0]
+			usr, _ := e["user"].(map[string]interface{})
+			if usr["password"] != "[REDACTED]" || usr["email"] != "b***@ardanlabs.com" {
+				t.Fatalf("\t%s\tTest 1:\tShould keep the structure of the value: got %v.", failed, e["user"])
+			}
+			t.Logf("\t%s\tTest 1:\tShould keep the structure of the value.", success)
+
+			if e["count"] != 1.0 {
+				t.Fatalf("\t%s\tTest 1:\tShould leave other fields alone: got %v.", failed, e["count"])
+			}
+			t.Logf("\t%s\tTest 1:\tShould leave other fields alone.", success)
+		}
+
+		t.Log("\tTest 2:\tWhen adding secrets to the logger.")
+		{
+			log.With("password", "gophers").Infow("with")
+			log.Sync()
+
+			if strings.Contains(buf.String(), "gophers") {
+				t.Fatalf("\t%s\tTest 2:\tShould redact fields added with With: got %s.", failed, buf.String())
+			}
+			t.Logf("\t%s\tTest 2:\tShould redact fields added with With.", success)
+		}
+
+		t.Log("\tTest 3:\tWhen the keys are spelled differently than configured.")
+		{
+			var buf bytes.Buffer
+			log, err := logger.New(logger.Config{
+				Service: "TEST",
+				Output:  &buf,
+				Redact:  []string{"password", "passwordConfirm"},
+			})
+			if err != nil {
+				t.Fatalf("\t%s\tTest 3:\tShould be able to construct the logger: %s", failed, err)
+			}
+
+			nu := newUser{Name: "Bill", Password: "gophers", PasswordConfirm: "gophers"}
+			log.Infow("create", "user", nu, "Password-Confirm", "gophers", "PASSWORD", "gophers")
+			log.Sync()
+
+			if strings.Contains(buf.String(), "gophers") {
+				t.Fatalf("\t%s\tTest 3:\tShould redact password_confirm: got %s.", failed, buf.String())
+			}
+			t.Logf("\t%s\tTest 3:\tShould redact password_confirm.", success)
+		}
+	}
+}
+
+func TestLevel(t *testing.T) {
+	var buf bytes.Buffer
+	level := zap.NewAtomicLevelAt(zap.InfoLevel)
+	log, err := logger.New(logger.Config{
+		Service: "TEST",
+		Output:  &buf,
+		Level:   level,
+	})
+	if err != nil {
+		t.Fatalf("\t%s\tShould be able to construct the logger: %s", failed, err)
+	}
+
+	t.Log("Given the need to control the log level at runtime.")
+	{
+		t.Log("\tTest 0:\tWhen logging below the level.")
+		{
+			log.Debugw("hidden")
+			log.Infow("shown")
+			log.Sync()
+
+			es := entries(t, &buf)
+			if len(es) != 1 || es[0]["msg"] != "shown" {
+				t.Fatalf("\t%s\tTest 0:\tShould drop debug entries at info: got %v.", failed, es)
+			}
+			t.Logf("\t%s\tTest 0:\tShould drop debug entries at info.", success)
+		}
+
+		t.Log("\tTest 1:\tWhen changing the level or forcing debug.")
+		{
+			logger.ForceDebug(log).Debugw("forced")
+			level.SetLevel(zap.DebugLevel)
+			log.Debugw("enabled")
+			level.SetLevel(zap.ErrorLevel)
+			log.Warnw("hidden")
+			log.Sync()
+
+			es := entries(t, &buf)
+			if len(es) != 2 || es[0]["msg"] != "forced" || es[1]["msg"] != "enabled" {
+				t.Fatalf("\t%s\tTest 1:\tShould follow the level and the forced debug logger: got %v.", failed, es)
+			}
+			t.Logf("\t%s\tTest 1:\tShould follow the level and the forced debug logger.", success)
+		}
+	}
+}
+
+func TestRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sales.log")
+	log, err := logger.New(logger.Config{
+		Service:        "TEST",
+		Output:         &bytes.Buffer{},
+		FilePath:       path,
+		FileMaxSize:    1,
+		FileMaxBackups: 1,
+	})
+	if err != nil {
+		t.Fatalf("\t%s\tShould be able to construct the logger: %s", failed, err)
+	}
+
+	t.Log("Given the need to keep the log file from growing forever.")
+	{
+		t.Log("\tTest 0:\tWhen writing more than twice the maximum size.")
+		{
+			msg := strings.Repeat("x", 4096)
+			for i := 0; i < 700; i++ {
+				log.Infow(msg)
+			}
+			log.Sync()
+
+			for _, p := range []string{path, path + ".1"} {
+				info, err := os.Stat(p)
+				if err != nil {
+					t.Fatalf("\t%s\tTest 0:\tShould have written %s: %s", failed, p, err)
+				}
+				if info.Size() > 1024*1024 {
+					t.Fatalf("\t%s\tTest 0:\tShould keep %s under the maximum size: got %d bytes.", failed, p, info.Size())
+				}
+			}
+			t.Logf("\t%s\tTest 0:\tShould rotate the file once it is full.", success)
+
+			if _, err := os.Stat(path + ".2"); !os.IsNotExist(err) {
+				t.Fatalf("\t%s\tTest 0:\tShould keep only the configured backups: %v", failed, err)
+			}
+			t.Logf("\t%s\tTest 0:\tShould keep only the configured backups.", success)
+		}
+	}
+}
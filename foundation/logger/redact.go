@@ -0,0 +1,204 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"unicode/utf8"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// redacted replaces the value of redacted fields.
+const redacted = "[REDACTED]"
+
+// redactRules holds the normalized keys of the fields to hide.
+type redactRules struct {
+	redact map[string]bool
+	mask   map[string]bool
+}
+
+// newRedactRules constructs the rules for the provided keys. Keys match
+// whatever their case and separators, so passwordConfirm also hides
+// password_confirm and Password-Confirm.
+func newRedactRules(redact []string, mask []string) redactRules {
+	rules := redactRules{
+		redact: make(map[string]bool),
+		mask:   make(map[string]bool),
+	}
+	for _, k := range redact {
+		rules.redact[normalizeKey(k)] = true
+	}
+	for _, k := range mask {
+		rules.mask[normalizeKey(k)] = true
+	}
+	return rules
+}
+
+// keySeparators are removed from keys before they are matched.
+var keySeparators = strings.NewReplacer("_", "", "-", "", " ", "")
+
+// normalizeKey returns the key lower-cased and without separators.
+func normalizeKey(key string) string {
+	return keySeparators.Replace(strings.ToLower(key))
+}
+
+// apply returns the fields with the matching values hidden. Values logged
+// as structs, maps or slices are searched for matching keys at any depth.
+// The provided slice is never modified.
+func (r redactRules) apply(fields []zapcore.Field) []zapcore.Field {
+	var out []zapcore.Field
+	for i, f := range fields {
+		key := normalizeKey(f.Key)
+
+		var replace zapcore.Field
+		switch {
+		case r.redact[key]:
+			replace = zap.String(f.Key, redacted)
+		case r.mask[key]:
+			replace = zap.String(f.Key, redacted)
+			if f.Type == zapcore.StringType {
+				replace = zap.String(f.Key, mask(f.String))
+			}
+		default:
+			v, ok := r.nested(f)
+			if !ok {
+				if out != nil {
+					out = append(out, f)
+				}
+				continue
+			}
+			replace = zap.Any(f.Key, v)
+		}
+
+		if out == nil {
+			out = make([]zapcore.Field, i, len(fields))
+			copy(out, fields[:i])
+		}
+		out = append(out, replace)
+	}
+
+	if out == nil {
+		return fields
+	}
+	return out
+}
+
+// nested returns the value of a field logged through reflection with the
+// matching values hidden, and false if nothing in it matched.
+func (r redactRules) nested(f zapcore.Field) (interface{}, bool) {
+	if f.Type != zapcore.ReflectType || f.Interface == nil {
+		return nil, false
+	}
+
+	// Work on the JSON form, which has the same keys the encoder writes.
+	b, err := json.Marshal(f.Interface)
+	if err != nil {
+		return nil, false
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, false
+	}
+
+	if !r.walk(v) {
+		return nil, false
+	}
+	return v, true
+}
+
+// walk hides the matching values of the decoded JSON value in place and
+// reports whether any matched.
+func (r redactRules) walk(v interface{}) bool {
+	var matched bool
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, val := range v {
+			key := normalizeKey(k)
+			switch {
+			case r.redact[key]:
+				v[k] = redacted
+				matched = true
+			case r.mask[key]:
+				v[k] = redacted
+				if s, ok := val.(string); ok {
+					v[k] = mask(s)
+				}
+				matched = true
+			default:
+				if r.walk(val) {
+					matched = true
+				}
+			}
+		}
+	case []interface{}:
+		for _, val := range v {
+			if r.walk(val) {
+				matched = true
+			}
+		}
+	}
+	return matched
+}
+
+// mask keeps the first character of the value and, for email addresses, the
+// domain. Everything else is replaced.
+func mask(s string) string {
+	if s == "" {
+		return s
+	}
+
+	local, domain, isEmail := strings.Cut(s, "@")
+	if !isEmail {
+		return firstRune(s) + "***"
+	}
+	if local == "" {
+		return "***@" + domain
+	}
+	return firstRune(local) + "***@" + domain
+}
+
+// firstRune returns the first character of the non empty string, which may
+// be more than one byte long.
+func firstRune(s string) string {
+	_, size := utf8.DecodeRuneInString(s)
+	return s[:size]
+}
+
+// redactCore hides the values of sensitive fields before they reach the
+// wrapped core.
+type redactCore struct {
+	zapcore.Core
+	rules redactRules
+}
+
+// redact wraps the core with the rules, unless there are none.
+func redact(core zapcore.Core, rules redactRules) zapcore.Core {
+	if len(rules.redact) == 0 && len(rules.mask) == 0 {
+		return core
+	}
+	return redactCore{core, rules}
+}
+
+// With implements the zapcore.Core interface.
+func (c redactCore) With(fields []zapcore.Field) zapcore.Core {
+	return redactCore{c.Core.With(c.rules.apply(fields)), c.rules}
+}
+
+// Check implements the zapcore.Core interface.
+func (c redactCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+// Write implements the zapcore.Core interface.
+func (c redactCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	return c.Core.Write(ent, c.rules.apply(fields))
+}
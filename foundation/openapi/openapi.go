@@ -0,0 +1,202 @@
+// Package openapi generates OpenAPI 3 documents from the routes registered
+// in a web.App.
+package openapi
+
+import (
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/mohammadhsn/ultimate-service/foundation/web"
+)
+
+// Version is the version of the OpenAPI specification documents follow.
+const Version = "3.0.3"
+
+// Document is an OpenAPI document.
+type Document struct {
+	OpenAPI    string                          `json:"openapi"`
+	Info       Info                            `json:"info"`
+	Paths      map[string]map[string]Operation `json:"paths"`
+	Components Components                      `json:"components"`
+}
+
+// Info holds the metadata of the API.
+type Info struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+// Components holds the schemas of the models referenced by operations and
+// the security schemes.
+type Components struct {
+	Schemas         map[string]*Schema        `json:"schemas,omitempty"`
+	SecuritySchemes map[string]SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+// SecurityScheme describes how clients authenticate.
+type SecurityScheme struct {
+	Type         string `json:"type"`
+	Scheme       string `json:"scheme,omitempty"`
+	BearerFormat string `json:"bearerFormat,omitempty"`
+}
+
+// Operation describes a single route.
+type Operation struct {
+	Summary     string                `json:"summary,omitempty"`
+	OperationID string                `json:"operationId"`
+	Parameters  []Parameter           `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]Response   `json:"responses"`
+	Security    []map[string][]string `json:"security,omitempty"`
+	Roles       []string              `json:"x-roles,omitempty"`
+}
+
+// Parameter describes a path parameter of an operation.
+type Parameter struct {
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required"`
+	Schema   *Schema `json:"schema"`
+}
+
+// RequestBody describes the body an operation reads.
+type RequestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+// Response describes a response of an operation.
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+// MediaType holds the schema of a body.
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// Config contains the settings of a generated document.
+type Config struct {
+	Title   string
+	Version string
+
+	// ErrorModel is a value of the type the API responds with on errors.
+	ErrorModel interface{}
+}
+
+// bearerAuth is the name of the security scheme of routes with roles.
+const bearerAuth = "bearerAuth"
+
+// Generate builds the OpenAPI document describing the routes.
+func Generate(cfg Config, routes []web.Route) Document {
+	g := generator{schemas: make(map[string]*Schema)}
+
+	doc := Document{
+		OpenAPI: Version,
+		Info: Info{
+			Title:   cfg.Title,
+			Version: cfg.Version,
+		},
+		Paths: make(map[string]map[string]Operation),
+	}
+
+	var errSchema *Schema
+	if cfg.ErrorModel != nil {
+		errSchema = g.schema(reflect.TypeOf(cfg.ErrorModel))
+	}
+
+	secured := false
+	for _, route := range routes {
+		path, params := convertPath(route.Path)
+
+		op := Operation{
+			Summary:     route.Doc.Summary,
+			OperationID: operationID(route.Method, path),
+			Responses:   make(map[string]Response),
+			Roles:       route.Doc.Roles,
+		}
+
+		for _, name := range params {
+			op.Parameters = append(op.Parameters, Parameter{
+				Name:     name,
+				In:       "path",
+				Required: true,
+				Schema:   &Schema{Type: "string"},
+			})
+		}
+
+		if route.Doc.Request != nil {
+			op.RequestBody = &RequestBody{
+				Required: true,
+				Content:  jsonContent(g.schema(reflect.TypeOf(route.Doc.Request))),
+			}
+		}
+
+		status := route.Doc.Status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		resp := Response{Description: http.StatusText(status)}
+		if route.Doc.Response != nil && status != http.StatusNoContent {
+			resp.Content = jsonContent(g.schema(reflect.TypeOf(route.Doc.Response)))
+		}
+		op.Responses[strconv.Itoa(status)] = resp
+
+		if errSchema != nil {
+			op.Responses["default"] = Response{
+				Description: "Error",
+				Content:     jsonContent(errSchema),
+			}
+		}
+
+		if len(route.Doc.Roles) > 0 {
+			op.Security = []map[string][]string{{bearerAuth: {}}}
+			secured = true
+		}
+
+		if doc.Paths[path] == nil {
+			doc.Paths[path] = make(map[string]Operation)
+		}
+		doc.Paths[path][strings.ToLower(route.Method)] = op
+	}
+
+	doc.Components.Schemas = g.schemas
+	if secured {
+		doc.Components.SecuritySchemes = map[string]SecurityScheme{
+			bearerAuth: {Type: "http", Scheme: "bearer", BearerFormat: "JWT"},
+		}
+	}
+
+	return doc
+}
+
+// convertPath turns the :name parameters of a route path into {name} and
+// returns their names.
+func convertPath(path string) (string, []string) {
+	var params []string
+
+	segments := strings.Split(path, "/")
+	for i, s := range segments {
+		if strings.HasPrefix(s, ":") || strings.HasPrefix(s, "*") {
+			params = append(params, s[1:])
+			segments[i] = "{" + s[1:] + "}"
+		}
+	}
+
+	return strings.Join(segments, "/"), params
+}
+
+// operationID derives a unique operation ID from the method and path, like
+// get_v1_users_id for GET /v1/users/{id}.
+func operationID(method, path string) string {
+	r := strings.NewReplacer("/", "_", "{", "", "}", "", ".", "_", "-", "_")
+	return strings.ToLower(method) + r.Replace(path)
+}
+
+// jsonContent returns the content of a JSON body with the schema.
+func jsonContent(s *Schema) map[string]MediaType {
+	return map[string]MediaType{"application/json": {Schema: s}}
+}
@@ -0,0 +1,111 @@
+package openapi_test
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/mohammadhsn/ultimate-service/foundation/openapi"
+	"github.com/mohammadhsn/ultimate-service/foundation/web"
+	"go.uber.org/zap"
+)
+
+const (
+	success = "\u2713"
+	failed  = "\u2717"
+)
+
+type newProduct struct {
+	Name     string   `json:"name" validate:"required"`
+	Cost     int      `json:"cost" validate:"required,gte=0"`
+	Quantity int      `json:"quantity" validate:"gte=1,lt=100"`
+	Tags     []string `json:"tags" validate:"max=3,dive,oneof=red green"`
+	Contact  *string  `json:"contact" validate:"omitempty,email"`
+	Internal string   `json:"-"`
+}
+
+type product struct {
+	ID string `json:"id"`
+}
+
+func TestGenerate(t *testing.T) {
+	t.Log("Given the need to describe the registered routes.")
+
+	app := web.NewApp(make(chan os.Signal, 1), zap.NewNop().Sugar())
+	h := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error { return nil }
+
+	g := app.Group("v1")
+	g.HandleDoc(http.MethodPost, "/products", web.Doc{
+		Summary:  "Create a product",
+		Request:  newProduct{},
+		Response: product{},
+		Status:   http.StatusCreated,
+		Roles:    []string{"ADMIN"},
+	}, h)
+	g.Handle(http.MethodGet, "/products/:id", h)
+
+	doc := openapi.Generate(openapi.Config{Title: "Test", Version: "1"}, app.Routes())
+
+	t.Log("\tTest 0:\tWhen generating the paths.")
+	{
+		create, ok := doc.Paths["/v1/products"]["post"]
+		if !ok {
+			t.Fatalf("\t%s\tTest 0:\tShould describe the POST route.", failed)
+		}
+		t.Logf("\t%s\tTest 0:\tShould describe the POST route.", success)
+
+		if _, ok := create.Responses["201"]; !ok {
+			t.Fatalf("\t%s\tTest 0:\tShould describe the 201 response: %v.", failed, create.Responses)
+		}
+		t.Logf("\t%s\tTest 0:\tShould describe the 201 response.", success)
+
+		if len(create.Security) != 1 || len(create.Roles) != 1 {
+			t.Fatalf("\t%s\tTest 0:\tShould require authentication for the roles.", failed)
+		}
+		t.Logf("\t%s\tTest 0:\tShould require authentication for the roles.", success)
+
+		query, ok := doc.Paths["/v1/products/{id}"]["get"]
+		if !ok || len(query.Parameters) != 1 || query.Parameters[0].Name != "id" {
+			t.Fatalf("\t%s\tTest 0:\tShould describe the id path parameter: %+v.", failed, query.Parameters)
+		}
+		t.Logf("\t%s\tTest 0:\tShould describe the id path parameter.", success)
+	}
+
+	t.Log("\tTest 1:\tWhen generating the model schemas.")
+	{
+		s, ok := doc.Components.Schemas["newProduct"]
+		if !ok {
+			t.Fatalf("\t%s\tTest 1:\tShould have the request model schema.", failed)
+		}
+		t.Logf("\t%s\tTest 1:\tShould have the request model schema.", success)
+
+		if len(s.Required) != 2 || s.Required[0] != "name" || s.Required[1] != "cost" {
+			t.Fatalf("\t%s\tTest 1:\tShould list the required fields: %v.", failed, s.Required)
+		}
+		t.Logf("\t%s\tTest 1:\tShould list the required fields.", success)
+
+		if _, ok := s.Properties["Internal"]; ok {
+			t.Fatalf("\t%s\tTest 1:\tShould skip the fields not in JSON.", failed)
+		}
+		t.Logf("\t%s\tTest 1:\tShould skip the fields not in JSON.", success)
+
+		qty := s.Properties["quantity"]
+		if qty.Minimum == nil || *qty.Minimum != 1 || qty.Maximum == nil || *qty.Maximum != 100 || !qty.ExclusiveMaximum {
+			t.Fatalf("\t%s\tTest 1:\tShould constrain numbers: %+v.", failed, qty)
+		}
+		t.Logf("\t%s\tTest 1:\tShould constrain numbers.", success)
+
+		tags := s.Properties["tags"]
+		if tags.MaxItems == nil || *tags.MaxItems != 3 || len(tags.Items.Enum) != 2 {
+			t.Fatalf("\t%s\tTest 1:\tShould constrain arrays and their items: %+v.", failed, tags)
+		}
+		t.Logf("\t%s\tTest 1:\tShould constrain arrays and their items.", success)
+
+		contact := s.Properties["contact"]
+		if contact.Format != "email" || !contact.Nullable {
+			t.Fatalf("\t%s\tTest 1:\tShould describe optional emails: %+v.", failed, contact)
+		}
+		t.Logf("\t%s\tTest 1:\tShould describe optional emails.", success)
+	}
+}
@@ -0,0 +1,247 @@
+package openapi
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schema describes a model or a field of a model.
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	ExclusiveMinimum     bool               `json:"exclusiveMinimum,omitempty"`
+	ExclusiveMaximum     bool               `json:"exclusiveMaximum,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	MaxItems             *int               `json:"maxItems,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+}
+
+// timeType is the type of time values, described as date-time strings.
+var timeType = reflect.TypeOf(time.Time{})
+
+// generator builds the schemas of models, collecting the named structs as
+// components.
+type generator struct {
+	schemas map[string]*Schema
+	names   map[reflect.Type]string
+}
+
+// schema returns the schema of the type. Named structs are referenced.
+func (g *generator) schema(t reflect.Type) *Schema {
+	switch t.Kind() {
+	case reflect.Ptr:
+		s := g.schema(t.Elem())
+		if s.Ref == "" {
+			s.Nullable = true
+		}
+		return s
+
+	case reflect.Struct:
+		if t == timeType {
+			return &Schema{Type: "string", Format: "date-time"}
+		}
+		if t.Name() == "" {
+			return g.object(t)
+		}
+		return &Schema{Ref: "#/components/schemas/" + g.component(t)}
+
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: g.schema(t.Elem())}
+
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: g.schema(t.Elem())}
+
+	case reflect.String:
+		return &Schema{Type: "string"}
+
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer", Format: "int32"}
+
+	case reflect.Int64, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+
+	case reflect.Float32:
+		return &Schema{Type: "number", Format: "float"}
+
+	case reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	}
+
+	return &Schema{}
+}
+
+// component registers the schema of a named struct and returns its name.
+// Structs of different packages with the same name get the package name as
+// a prefix.
+func (g *generator) component(t reflect.Type) string {
+	if g.names == nil {
+		g.names = make(map[reflect.Type]string)
+	}
+	if name, ok := g.names[t]; ok {
+		return name
+	}
+
+	name := t.Name()
+	if _, taken := g.schemas[name]; taken {
+		pkg := t.PkgPath()
+		name = pkg[strings.LastIndex(pkg, "/")+1:] + "." + name
+	}
+
+	// Register the name before building the schema, so recursive types
+	// reference themselves.
+	g.names[t] = name
+	g.schemas[name] = &Schema{}
+	*g.schemas[name] = *g.object(t)
+
+	return name
+}
+
+// object builds the schema of a struct from the JSON names of its fields,
+// with the constraints of their validate tags.
+func (g *generator) object(t reflect.Type) *Schema {
+	s := Schema{
+		Type:       "object",
+		Properties: make(map[string]*Schema),
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+
+		name := strings.SplitN(f.Tag.Get("json"), ",", 2)[0]
+		if name == "-" {
+			continue
+		}
+
+		// Embedded structs without a JSON name are flattened.
+		if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct {
+			embedded := g.object(f.Type)
+			for k, v := range embedded.Properties {
+				s.Properties[k] = v
+			}
+			s.Required = append(s.Required, embedded.Required...)
+			continue
+		}
+
+		if name == "" {
+			name = f.Name
+		}
+
+		fs := g.schema(f.Type)
+		if constrain(fs, f.Tag.Get("validate")) {
+			s.Required = append(s.Required, name)
+		}
+		s.Properties[name] = fs
+	}
+
+	return &s
+}
+
+// constrain applies the rules of a validate tag to the schema and reports
+// whether the field is required. Rules following dive apply to the items.
+func constrain(s *Schema, tag string) bool {
+	if tag == "" || s.Ref != "" {
+		return strings.Contains(","+tag+",", ",required,")
+	}
+
+	required := false
+	target := s
+	for _, rule := range strings.Split(tag, ",") {
+		name, param := rule, ""
+		if i := strings.Index(rule, "="); i >= 0 {
+			name, param = rule[:i], rule[i+1:]
+		}
+
+		switch name {
+		case "required":
+			if target == s {
+				required = true
+			}
+		case "dive":
+			if target.Items == nil || target.Items.Ref != "" {
+				return required
+			}
+			target = target.Items
+		case "email":
+			target.Format = "email"
+		case "uuid", "uuid4":
+			target.Format = "uuid"
+		case "url", "uri":
+			target.Format = "uri"
+		case "oneof":
+			target.Enum = strings.Fields(param)
+		case "min", "gte":
+			bound(target, param, true, false)
+		case "max", "lte":
+			bound(target, param, false, false)
+		case "gt":
+			bound(target, param, true, true)
+		case "lt":
+			bound(target, param, false, true)
+		case "len":
+			bound(target, param, true, false)
+			bound(target, param, false, false)
+		}
+	}
+
+	return required
+}
+
+// bound sets a lower or upper bound on the schema. It limits the length of
+// strings, the number of items of arrays and the value of numbers.
+func bound(s *Schema, param string, lower, exclusive bool) {
+	v, err := strconv.ParseFloat(param, 64)
+	if err != nil {
+		return
+	}
+
+	switch s.Type {
+	case "string", "array":
+		n := int(v)
+		if exclusive {
+			if lower {
+				n++
+			} else {
+				n--
+			}
+		}
+		switch {
+		case s.Type == "string" && lower:
+			s.MinLength = &n
+		case s.Type == "string":
+			s.MaxLength = &n
+		case lower:
+			s.MinItems = &n
+		default:
+			s.MaxItems = &n
+		}
+
+	case "integer", "number":
+		if lower {
+			s.Minimum, s.ExclusiveMinimum = &v, exclusive
+		} else {
+			s.Maximum, s.ExclusiveMaximum = &v, exclusive
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package web
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+)
+
+// conditional holds the preconditions of a request, taken from the request
+// headers.
+type conditional struct {
+	method          string
+	ifNoneMatch     string
+	ifModifiedSince string
+}
+
+// newConditional reads the precondition headers of the request.
+func newConditional(r *http.Request) conditional {
+	return conditional{
+		method:          r.Method,
+		ifNoneMatch:     r.Header.Get("If-None-Match"),
+		ifModifiedSince: r.Header.Get("If-Modified-Since"),
+	}
+}
+
+// cacheable reports whether the request may be answered with a 304.
+func (c conditional) cacheable() bool {
+	return c.method == http.MethodGet || c.method == http.MethodHead
+}
+
+// notModified reports whether the client already has the response described
+// by the ETag and Last-Modified headers. If-Modified-Since is only looked at
+// when the request has no If-None-Match header.
+func (c conditional) notModified(h http.Header) bool {
+	if c.ifNoneMatch != "" {
+		etag := h.Get("ETag")
+		return etag != "" && MatchETag(c.ifNoneMatch, etag, true)
+	}
+
+	if c.ifModifiedSince == "" || h.Get("Last-Modified") == "" {
+		return false
+	}
+
+	since, err := http.ParseTime(c.ifModifiedSince)
+	if err != nil {
+		return false
+	}
+	modified, err := http.ParseTime(h.Get("Last-Modified"))
+	if err != nil {
+		return false
+	}
+
+	return !modified.After(since)
+}
+
+// weakETag returns an entity tag derived from the response body. It is weak
+// since the body may still be compressed differently.
+func weakETag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return "W/" + ETag(hex.EncodeToString(sum[:16]))
+}
@@ -12,11 +12,32 @@ type ctxKey int
 // key is how request values are stored/received.
 const key ctxKey = 1
 
-// Values represent state for each request.
+// Values represent state for each request. The status code, size and first
+// byte time are recorded as the response is written.
 type Values struct {
 	TraceID    string
+	Route      string
 	Now        time.Time
 	StatusCode int
+	Size       int64
+	FirstByte  time.Time
+
+	negotiation negotiation
+	conditional conditional
+}
+
+// TimeToFirstByte returns the time from the start of the request until the
+// status code was written, or zero if nothing has been written yet.
+func (v *Values) TimeToFirstByte() time.Duration {
+	if v.FirstByte.IsZero() {
+		return 0
+	}
+	return v.FirstByte.Sub(v.Now)
+}
+
+// Duration returns the time since the start of the request.
+func (v *Values) Duration() time.Duration {
+	return time.Since(v.Now)
 }
 
 // GetValues returns the values from the context.
@@ -28,17 +49,11 @@ func GetValues(ctx context.Context) (*Values, error) {
 	return v, nil
 }
 
-// SetStatusCode sets the status code back into the context.
-func SetStatusCode(ctx context.Context, statusCode int) error {
+// GetTraceID finds the trace id from a context.
+func GetTraceID(ctx context.Context) string {
 	v, ok := ctx.Value(key).(*Values)
 	if !ok {
-		return errors.New("web value missing from context")
+		return "00000000000000000000000000000000"
 	}
-	v.StatusCode = statusCode
-	return nil
-}
-
-// GetTraceID finds the trace id from a context.
-func GetTraceID(ctx context.Context) string {
-	return ""
+	return v.TraceID
 }
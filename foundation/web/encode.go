@@ -0,0 +1,173 @@
+package web
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// marshalMsgPack encodes the data as MessagePack. Struct fields are named
+// after their json tags so both formats share the same field names.
+func marshalMsgPack(data interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+
+	enc := msgpack.NewEncoder(&buf)
+	enc.SetCustomStructTag("json")
+	enc.UseCompactInts(true)
+
+	if err := enc.Encode(data); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// marshalCSV encodes a slice of structs as CSV, with a header row built from
+// the json tags of the struct fields. It reports false if the data is not a
+// slice of structs.
+func marshalCSV(data interface{}) ([]byte, bool, error) {
+	val := reflect.ValueOf(data)
+	for val.Kind() == reflect.Ptr {
+		if val.IsNil() {
+			return nil, false, nil
+		}
+		val = val.Elem()
+	}
+
+	if val.Kind() != reflect.Slice && val.Kind() != reflect.Array {
+		return nil, false, nil
+	}
+
+	elem := val.Type().Elem()
+	for elem.Kind() == reflect.Ptr {
+		elem = elem.Elem()
+	}
+	if elem.Kind() != reflect.Struct {
+		return nil, false, nil
+	}
+
+	fields, header := csvColumns(elem)
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+
+	if err := w.Write(header); err != nil {
+		return nil, true, err
+	}
+
+	row := make([]string, len(fields))
+	for i := 0; i < val.Len(); i++ {
+		if err := csvRow(val.Index(i), fields, row); err != nil {
+			return nil, true, err
+		}
+		if err := w.Write(row); err != nil {
+			return nil, true, err
+		}
+	}
+
+	w.Flush()
+	return buf.Bytes(), true, w.Error()
+}
+
+// csvStruct returns the struct type of the value, which may be a pointer to
+// a struct. It reports false if the value is not a struct.
+func csvStruct(v interface{}) (reflect.Type, bool) {
+	t := reflect.TypeOf(v)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil, false
+	}
+	return t, true
+}
+
+// csvRow fills the row with the cells of the fields of the struct item. The
+// cells of a nil pointer are empty.
+func csvRow(item reflect.Value, fields []int, row []string) error {
+	for item.Kind() == reflect.Ptr || item.Kind() == reflect.Interface {
+		item = item.Elem()
+	}
+
+	for j, idx := range fields {
+		if !item.IsValid() {
+			row[j] = ""
+			continue
+		}
+		cell, err := csvCell(item.Field(idx))
+		if err != nil {
+			return err
+		}
+		row[j] = cell
+	}
+	return nil
+}
+
+// csvColumns returns the indexes of the exported struct fields that are part
+// of the JSON representation, and their names.
+func csvColumns(t reflect.Type) ([]int, []string) {
+	var fields []int
+	var header []string
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+
+		name := strings.SplitN(f.Tag.Get("json"), ",", 2)[0]
+		switch name {
+		case "-", "_":
+			continue
+		case "":
+			name = f.Name
+		}
+
+		fields = append(fields, i)
+		header = append(header, name)
+	}
+	return fields, header
+}
+
+// csvCell formats a single field value. Lists of strings are joined with a
+// semicolon and other composite values are written as JSON.
+func csvCell(v reflect.Value) (string, error) {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return "", nil
+		}
+		v = v.Elem()
+	}
+
+	if t, ok := v.Interface().(time.Time); ok {
+		return t.Format(time.RFC3339), nil
+	}
+
+	switch v.Kind() {
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return fmt.Sprint(v.Interface()), nil
+
+	case reflect.Slice, reflect.Array:
+		if v.Type().Elem().Kind() == reflect.String {
+			items := make([]string, v.Len())
+			for i := range items {
+				items[i] = v.Index(i).String()
+			}
+			return strings.Join(items, ";"), nil
+		}
+	}
+
+	b, err := json.Marshal(v.Interface())
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
@@ -0,0 +1,38 @@
+package web
+
+import "strings"
+
+// ETag quotes the value into an entity tag.
+func ETag(value string) string {
+	return `"` + value + `"`
+}
+
+// MatchETag reports whether the entity tag matches one of the tags listed
+// in an If-Match or If-None-Match header value. A "*" matches any tag. The
+// weak comparison, used for If-None-Match, ignores the W/ prefix of weak
+// tags, the strong comparison, used for If-Match, never matches them.
+func MatchETag(header, etag string, weak bool) bool {
+	if strings.TrimSpace(header) == "*" {
+		return true
+	}
+
+	if weak {
+		etag = strings.TrimPrefix(etag, "W/")
+	} else if strings.HasPrefix(etag, "W/") {
+		return false
+	}
+
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if weak {
+			tag = strings.TrimPrefix(tag, "W/")
+		} else if strings.HasPrefix(tag, "W/") {
+			continue
+		}
+		if tag == etag {
+			return true
+		}
+	}
+
+	return false
+}
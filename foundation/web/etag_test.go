@@ -0,0 +1,94 @@
+package web_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/mohammadhsn/ultimate-service/foundation/web"
+	"go.uber.org/zap"
+)
+
+func TestMatchETag(t *testing.T) {
+	t.Log("Given the need to compare entity tags with conditional headers.")
+
+	tt := []struct {
+		header string
+		etag   string
+		weak   bool
+		match  bool
+	}{
+		{header: `"1"`, etag: `"1"`, match: true},
+		{header: `"1"`, etag: `"2"`, match: false},
+		{header: `"1", "2"`, etag: `"2"`, match: true},
+		{header: `*`, etag: `"3"`, match: true},
+		{header: `W/"1"`, etag: `"1"`, match: false},
+		{header: `W/"1"`, etag: `"1"`, weak: true, match: true},
+		{header: `"1"`, etag: `W/"1"`, weak: true, match: true},
+		{header: `"1"`, etag: `W/"1"`, match: false},
+	}
+
+	for i, tc := range tt {
+		t.Logf("\tTest %d:\tWhen matching %s against %s, weak %t.", i, tc.etag, tc.header, tc.weak)
+		{
+			if got := web.MatchETag(tc.header, tc.etag, tc.weak); got != tc.match {
+				t.Fatalf("\t%s\tTest %d:\tShould get %t: got %t.", failed, i, tc.match, got)
+			}
+			t.Logf("\t%s\tTest %d:\tShould get %t.", success, i, tc.match)
+		}
+	}
+}
+
+func TestConditionalGet(t *testing.T) {
+	t.Log("Given the need to answer conditional GET requests.")
+
+	modified := time.Date(2022, time.March, 1, 10, 0, 0, 0, time.UTC)
+
+	app := web.NewApp(make(chan os.Signal, 1), zap.NewNop().Sugar())
+	app.Handle(http.MethodGet, "", "/hashed", func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		return web.Respond(ctx, w, map[string]string{"name": "gopher"}, http.StatusOK)
+	})
+	app.Handle(http.MethodGet, "", "/dated", func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		w.Header().Set("Last-Modified", modified.Format(http.TimeFormat))
+		return web.Respond(ctx, w, map[string]string{"name": "gopher"}, http.StatusOK)
+	})
+
+	r := httptest.NewRequest(http.MethodGet, "/hashed", nil)
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, r)
+	etag := w.Header().Get("ETag")
+
+	tt := []struct {
+		path   string
+		header string
+		value  string
+		status int
+	}{
+		{path: "/hashed", header: "If-None-Match", value: etag, status: http.StatusNotModified},
+		{path: "/hashed", header: "If-None-Match", value: `W/"other"`, status: http.StatusOK},
+		{path: "/dated", header: "If-Modified-Since", value: modified.Format(http.TimeFormat), status: http.StatusNotModified},
+		{path: "/dated", header: "If-Modified-Since", value: modified.Add(-time.Hour).Format(http.TimeFormat), status: http.StatusOK},
+	}
+
+	for i, tc := range tt {
+		t.Logf("\tTest %d:\tWhen requesting %s with %s %s.", i, tc.path, tc.header, tc.value)
+		{
+			r := httptest.NewRequest(http.MethodGet, tc.path, nil)
+			r.Header.Set(tc.header, tc.value)
+			w := httptest.NewRecorder()
+			app.ServeHTTP(w, r)
+
+			if w.Code != tc.status {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a %d status code: %d.", failed, i, tc.status, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a %d status code.", success, i, tc.status)
+
+			if tc.status == http.StatusNotModified && w.Body.Len() != 0 {
+				t.Fatalf("\t%s\tTest %d:\tShould receive no body: %q.", failed, i, w.Body.String())
+			}
+		}
+	}
+}
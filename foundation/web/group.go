@@ -0,0 +1,63 @@
+package web
+
+import "strings"
+
+// Group is a set of routes sharing a path prefix and a middleware stack. The
+// group middleware runs after the application's general middleware and
+// before any route specific middleware.
+type Group struct {
+	app    *App
+	prefix string
+	mw     []Middleware
+}
+
+// Group creates a route group under the prefix with its own middleware.
+func (a *App) Group(prefix string, mw ...Middleware) *Group {
+	return &Group{
+		app:    a,
+		prefix: cleanPrefix(prefix),
+		mw:     mw,
+	}
+}
+
+// Group creates a group nested in this one. Its prefix is appended to the
+// parent prefix and its middleware runs after the parent middleware.
+func (g *Group) Group(prefix string, mw ...Middleware) *Group {
+	all := make([]Middleware, 0, len(g.mw)+len(mw))
+	all = append(all, g.mw...)
+	all = append(all, mw...)
+
+	return &Group{
+		app:    g.app,
+		prefix: g.prefix + cleanPrefix(prefix),
+		mw:     all,
+	}
+}
+
+// Handle sets a handler function for a given HTTP method and path pair,
+// relative to the group prefix, to the application server mux.
+func (g *Group) Handle(method, path string, handler Handler, mw ...Middleware) {
+	g.HandleDoc(method, path, Doc{}, handler, mw...)
+}
+
+// HandleDoc is like Handle and also describes the route for the API
+// documentation.
+func (g *Group) HandleDoc(method, path string, doc Doc, handler Handler, mw ...Middleware) {
+	// First wrap handler specific middleware around this handler.
+	handler = wrapMiddleware(mw, handler)
+
+	// Add the group middleware to the handler chain.
+	handler = wrapMiddleware(g.mw, handler)
+
+	g.app.handle(method, g.prefix+path, doc, handler)
+}
+
+// cleanPrefix makes sure the prefix starts with a slash and does not end
+// with one, so prefixes and paths can be joined.
+func cleanPrefix(prefix string) string {
+	prefix = strings.TrimRight(prefix, "/")
+	if prefix != "" && !strings.HasPrefix(prefix, "/") {
+		prefix = "/" + prefix
+	}
+	return prefix
+}
@@ -0,0 +1,78 @@
+package web_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/mohammadhsn/ultimate-service/foundation/web"
+	"go.uber.org/zap"
+)
+
+const (
+	success = "\u2713"
+	failed  = "\u2717"
+)
+
+// record returns a middleware appending the name to the trail when it runs.
+func record(trail *[]string, name string) web.Middleware {
+	return func(handler web.Handler) web.Handler {
+		return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+			*trail = append(*trail, name)
+			return handler(ctx, w, r)
+		}
+	}
+}
+
+func TestGroup(t *testing.T) {
+	var trail []string
+
+	app := web.NewApp(make(chan os.Signal, 1), zap.NewNop().Sugar(), record(&trail, "app"))
+
+	v1 := app.Group("v1", record(&trail, "v1"))
+	admin := v1.Group("/admin/", record(&trail, "admin"))
+
+	h := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		trail = append(trail, "handler")
+		return web.Respond(ctx, w, nil, http.StatusNoContent)
+	}
+	admin.Handle(http.MethodGet, "/users", h, record(&trail, "route"))
+	v1.Handle(http.MethodGet, "/users", h)
+
+	t.Log("Given the need to group routes under a prefix with shared middleware.")
+
+	testId := 0
+	t.Logf("\tTest %d:\tWhen handling a route of a nested group.", testId)
+	{
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/admin/users", nil))
+
+		if w.Code != http.StatusNoContent {
+			t.Fatalf("\t%s\tTest %d:\tShould receive a %d status code : %d.", failed, testId, http.StatusNoContent, w.Code)
+		}
+		t.Logf("\t%s\tTest %d:\tShould receive a %d status code.", success, testId, http.StatusNoContent)
+
+		exp := "app,v1,admin,route,handler"
+		if got := strings.Join(trail, ","); got != exp {
+			t.Fatalf("\t%s\tTest %d:\tShould run the middleware in order : got %s, exp %s.", failed, testId, got, exp)
+		}
+		t.Logf("\t%s\tTest %d:\tShould run the middleware in order.", success, testId)
+	}
+
+	trail = nil
+	testId++
+	t.Logf("\tTest %d:\tWhen handling a route of the parent group.", testId)
+	{
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/users", nil))
+
+		exp := "app,v1,handler"
+		if got := strings.Join(trail, ","); got != exp {
+			t.Fatalf("\t%s\tTest %d:\tShould not run the nested group middleware : got %s, exp %s.", failed, testId, got, exp)
+		}
+		t.Logf("\t%s\tTest %d:\tShould not run the nested group middleware.", success, testId)
+	}
+}
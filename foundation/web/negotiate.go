@@ -0,0 +1,143 @@
+package web
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Formats the response can be encoded in.
+const (
+	formatJSON    = "json"
+	formatCSV     = "csv"
+	formatMsgPack = "msgpack"
+	formatNDJSON  = "ndjson"
+)
+
+// mediaTypes maps the supported media types to their format.
+var mediaTypes = map[string]string{
+	"application/json":      formatJSON,
+	"text/csv":              formatCSV,
+	"application/msgpack":   formatMsgPack,
+	"application/x-msgpack": formatMsgPack,
+	"application/x-ndjson":  formatNDJSON,
+}
+
+// negotiation holds what the client accepts, taken from the request headers.
+type negotiation struct {
+	accept         string
+	acceptEncoding string
+	pretty         bool
+}
+
+// newNegotiation reads the negotiation headers and parameters of the request.
+func newNegotiation(r *http.Request) negotiation {
+	pretty := false
+	if v, ok := r.URL.Query()["pretty"]; ok {
+		pretty = len(v) == 0 || v[0] == "" || v[0] == "true" || v[0] == "1"
+	}
+
+	return negotiation{
+		accept:         r.Header.Get("Accept"),
+		acceptEncoding: r.Header.Get("Accept-Encoding"),
+		pretty:         pretty,
+	}
+}
+
+// streamTypes maps the media types streamed responses support to their
+// format. MessagePack is left out, its arrays start with their length.
+var streamTypes = map[string]string{
+	"application/json":     formatJSON,
+	"text/csv":             formatCSV,
+	"application/x-ndjson": formatNDJSON,
+}
+
+// format returns the supported format the client prefers. Without an Accept
+// header, or when the client accepts any type, it is JSON. It reports false
+// if the client accepts none of the formats. A media type listed by name
+// wins over a wildcard of the same quality.
+func (n negotiation) format() (string, bool) {
+	return n.formatOf(mediaTypes)
+}
+
+// streamFormat returns the format of a streamed response the client
+// prefers, like format does.
+func (n negotiation) streamFormat() (string, bool) {
+	return n.formatOf(streamTypes)
+}
+
+// formatOf returns the format of the media types the client prefers.
+func (n negotiation) formatOf(types map[string]string) (string, bool) {
+	if strings.TrimSpace(n.accept) == "" {
+		return formatJSON, true
+	}
+
+	best := formatJSON
+	bestQ := 0.0
+	bestExact := false
+	for _, mt := range parseHeader(n.accept) {
+		f, exact := types[mt.value]
+		if !exact {
+			if mt.value != "*/*" && mt.value != "application/*" {
+				continue
+			}
+			f = formatJSON
+		}
+		if mt.q > bestQ || (mt.q == bestQ && mt.q > 0 && exact && !bestExact) {
+			best, bestQ, bestExact = f, mt.q, exact
+		}
+	}
+	return best, bestQ > 0
+}
+
+// encoding returns the content encoding the client prefers, brotli winning
+// ties, or an empty string if it accepts neither brotli nor gzip.
+func (n negotiation) encoding() string {
+	var best string
+	bestQ := 0.0
+	for _, e := range parseHeader(n.acceptEncoding) {
+		if e.value != "br" && e.value != "gzip" {
+			continue
+		}
+		if e.q > bestQ || (e.q == bestQ && e.value == "br") {
+			best, bestQ = e.value, e.q
+		}
+	}
+	return best
+}
+
+// headerValue is a single value of a header with quality values.
+type headerValue struct {
+	value string
+	q     float64
+}
+
+// parseHeader parses a comma separated header like Accept into its values
+// and their quality. Values without a q parameter have a quality of 1.
+func parseHeader(header string) []headerValue {
+	var values []headerValue
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(part, ";")
+
+		hv := headerValue{
+			value: strings.ToLower(strings.TrimSpace(fields[0])),
+			q:     1,
+		}
+		if hv.value == "" {
+			continue
+		}
+
+		for _, param := range fields[1:] {
+			k, v, ok := strings.Cut(strings.TrimSpace(param), "=")
+			if !ok || strings.TrimSpace(k) != "q" {
+				continue
+			}
+			if q, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil {
+				hv.q = q
+			}
+		}
+
+		values = append(values, hv)
+	}
+	return values
+}
@@ -1,16 +1,28 @@
 package web
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
 	"encoding/json"
 	"net/http"
+
+	"github.com/andybalholm/brotli"
 )
 
-// Respond converts a Go value to JSON and sends it to the client.
-func Respond(ctx context.Context, w http.ResponseWriter, data interface{}, statusCode int) error {
+// compressThreshold is the response size in bytes above which responses are
+// compressed, when the client accepts it. Smaller responses are not worth it.
+const compressThreshold = 1024
 
-	// Set the status code for the request logger middleware.
-	SetStatusCode(ctx, statusCode)
+// Respond converts a Go value to the representation the client asked for in
+// the Accept header and sends it to the client. JSON is used unless CSV or
+// MessagePack were requested and the value can be represented in them. A
+// client accepting none of them is answered with a 406, unless the response
+// already is an error. JSON is indented when the request has a pretty query
+// parameter. Successful GET responses get an ETag, unless the handler set
+// one, and are answered with a 304 when the client's copy is still current.
+// Large responses are compressed according to the Accept-Encoding header.
+func Respond(ctx context.Context, w http.ResponseWriter, data interface{}, statusCode int) error {
 
 	// If there is nothing to marshal then set status code and return.
 	if statusCode == http.StatusNoContent {
@@ -18,22 +30,119 @@ func Respond(ctx context.Context, w http.ResponseWriter, data interface{}, statu
 		return nil
 	}
 
-	// Convert the response value to JSON.
-	jsonData, err := json.Marshal(data)
+	var neg negotiation
+	var cond conditional
+	if v, err := GetValues(ctx); err == nil {
+		neg = v.negotiation
+		cond = v.conditional
+	}
+
+	format, ok := neg.format()
+	if !ok && statusCode < http.StatusBadRequest {
+		statusCode = http.StatusNotAcceptable
+		data = notAcceptable()
+	}
+
+	// Convert the response value to the negotiated representation.
+	contentType, body, err := encode(format, neg.pretty, data)
 	if err != nil {
 		return err
 	}
 
+	if statusCode == http.StatusOK && cond.cacheable() {
+		if w.Header().Get("ETag") == "" {
+			w.Header().Set("ETag", weakETag(body))
+		}
+
+		if cond.notModified(w.Header()) {
+			w.Header().Add("Vary", "Accept")
+			w.Header().Add("Vary", "Accept-Encoding")
+			w.WriteHeader(http.StatusNotModified)
+			return nil
+		}
+	}
+
 	// Set the content type and headers once we know marshaling has succeeded.
-	w.Header().Set("Content-Type", "application/json")
+	// The encoding depends on the Accept-Encoding header even when the body
+	// is too small to be compressed, so caches must not share it.
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Add("Vary", "Accept")
+	w.Header().Add("Vary", "Accept-Encoding")
+
+	if len(body) >= compressThreshold {
+		if encoding := neg.encoding(); encoding != "" {
+			compressed, err := compress(encoding, body)
+			if err != nil {
+				return err
+			}
+			body = compressed
+			w.Header().Set("Content-Encoding", encoding)
+		}
+	}
 
 	// Write the status code to the response.
 	w.WriteHeader(statusCode)
 
 	// Send the result back to the client.
-	if _, err := w.Write(jsonData); err != nil {
+	if _, err := w.Write(body); err != nil {
 		return err
 	}
 
 	return nil
 }
+
+// notAcceptable returns the body of a 406 response.
+func notAcceptable() interface{} {
+	return struct {
+		Error string `json:"error"`
+	}{
+		Error: http.StatusText(http.StatusNotAcceptable),
+	}
+}
+
+// encode marshals the data into the negotiated format, falling back to JSON
+// when the data can not be represented in it.
+func encode(format string, pretty bool, data interface{}) (string, []byte, error) {
+	switch format {
+	case formatCSV:
+		if body, ok, err := marshalCSV(data); ok {
+			return "text/csv; charset=utf-8", body, err
+		}
+	case formatMsgPack:
+		body, err := marshalMsgPack(data)
+		return "application/msgpack", body, err
+	}
+
+	if pretty {
+		body, err := json.MarshalIndent(data, "", "    ")
+		return "application/json", body, err
+	}
+
+	body, err := json.Marshal(data)
+	return "application/json", body, err
+}
+
+// compress encodes the body with the content encoding.
+func compress(encoding string, body []byte) ([]byte, error) {
+	var buf bytes.Buffer
+
+	var zw interface {
+		Write([]byte) (int, error)
+		Close() error
+	}
+	switch encoding {
+	case "br":
+		zw = brotli.NewWriterLevel(&buf, brotli.DefaultCompression)
+	default:
+		zw = gzip.NewWriter(&buf)
+	}
+
+	if _, err := zw.Write(body); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,245 @@
+package web_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/mohammadhsn/ultimate-service/foundation/web"
+	"github.com/vmihailenco/msgpack/v5"
+	"go.uber.org/zap"
+)
+
+// person is the value the negotiation tests respond with.
+type person struct {
+	Name  string   `json:"name"`
+	Roles []string `json:"roles"`
+}
+
+// negotiationApp returns an app responding with a list of people, a single
+// person, a list large enough to be compressed and an error.
+func negotiationApp() *web.App {
+	people := []person{
+		{Name: "Bill", Roles: []string{"ADMIN", "USER"}},
+		{Name: "Ale", Roles: []string{"USER"}},
+	}
+
+	many := make([]person, 100)
+	for i := range many {
+		many[i] = person{Name: strings.Repeat("gopher", 5), Roles: []string{"USER"}}
+	}
+
+	app := web.NewApp(make(chan os.Signal, 1), zap.NewNop().Sugar())
+	app.Handle(http.MethodGet, "", "/people", func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		return web.Respond(ctx, w, people, http.StatusOK)
+	})
+	app.Handle(http.MethodGet, "", "/person", func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		return web.Respond(ctx, w, people[0], http.StatusOK)
+	})
+	app.Handle(http.MethodGet, "", "/many", func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		return web.Respond(ctx, w, many, http.StatusOK)
+	})
+	app.Handle(http.MethodGet, "", "/error", func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		return web.Respond(ctx, w, map[string]string{"error": "bad request"}, http.StatusBadRequest)
+	})
+
+	return app
+}
+
+// varies reports if the response varies on the header.
+func varies(w *httptest.ResponseRecorder, header string) bool {
+	for _, v := range w.Header().Values("Vary") {
+		if strings.EqualFold(v, header) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestNegotiation(t *testing.T) {
+	app := negotiationApp()
+
+	t.Log("Given the need to respond in the representation the client accepts.")
+
+	tt := []struct {
+		path        string
+		accept      string
+		statusCode  int
+		contentType string
+	}{
+		{path: "/people", accept: "", statusCode: http.StatusOK, contentType: "application/json"},
+		{path: "/people", accept: "application/json", statusCode: http.StatusOK, contentType: "application/json"},
+		{path: "/people", accept: "text/csv", statusCode: http.StatusOK, contentType: "text/csv; charset=utf-8"},
+		{path: "/people", accept: "application/msgpack", statusCode: http.StatusOK, contentType: "application/msgpack"},
+		{path: "/people", accept: "application/x-msgpack", statusCode: http.StatusOK, contentType: "application/msgpack"},
+		{path: "/people", accept: "text/csv;q=0.5, application/msgpack", statusCode: http.StatusOK, contentType: "application/msgpack"},
+		{path: "/people", accept: "application/json;q=0.2, TEXT/CSV;q=0.8", statusCode: http.StatusOK, contentType: "text/csv; charset=utf-8"},
+		{path: "/people", accept: "text/csv;q=0, application/json", statusCode: http.StatusOK, contentType: "application/json"},
+		{path: "/people", accept: "*/*", statusCode: http.StatusOK, contentType: "application/json"},
+		{path: "/people", accept: "*/*, text/csv", statusCode: http.StatusOK, contentType: "text/csv; charset=utf-8"},
+		{path: "/people", accept: "text/html, application/*;q=0.1", statusCode: http.StatusOK, contentType: "application/json"},
+		{path: "/person", accept: "text/csv", statusCode: http.StatusOK, contentType: "application/json"},
+		{path: "/people", accept: "text/html", statusCode: http.StatusNotAcceptable, contentType: "application/json"},
+		{path: "/people", accept: "application/json;q=0", statusCode: http.StatusNotAcceptable, contentType: "application/json"},
+		{path: "/error", accept: "text/html", statusCode: http.StatusBadRequest, contentType: "application/json"},
+	}
+
+	for i, tc := range tt {
+		t.Logf("\tTest %d:\tWhen getting %s with Accept %q.", i, tc.path, tc.accept)
+		{
+			r := httptest.NewRequest(http.MethodGet, tc.path, nil)
+			if tc.accept != "" {
+				r.Header.Set("Accept", tc.accept)
+			}
+			w := httptest.NewRecorder()
+			app.ServeHTTP(w, r)
+
+			if w.Code != tc.statusCode {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a %d status code : %d.", failed, i, tc.statusCode, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a %d status code.", success, i, tc.statusCode)
+
+			if got := w.Header().Get("Content-Type"); got != tc.contentType {
+				t.Fatalf("\t%s\tTest %d:\tShould respond with %s : got %s.", failed, i, tc.contentType, got)
+			}
+			t.Logf("\t%s\tTest %d:\tShould respond with %s.", success, i, tc.contentType)
+
+			if !varies(w, "Accept") {
+				t.Fatalf("\t%s\tTest %d:\tShould vary on Accept : got %v.", failed, i, w.Header().Values("Vary"))
+			}
+			t.Logf("\t%s\tTest %d:\tShould vary on Accept.", success, i)
+		}
+	}
+}
+
+func TestEncoding(t *testing.T) {
+	app := negotiationApp()
+
+	t.Log("Given the need to encode lists as CSV and values as MessagePack.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen getting a list as CSV.", testID)
+		{
+			r := httptest.NewRequest(http.MethodGet, "/people", nil)
+			r.Header.Set("Accept", "text/csv")
+			w := httptest.NewRecorder()
+			app.ServeHTTP(w, r)
+
+			records, err := csv.NewReader(w.Body).ReadAll()
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to read the CSV : %s.", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to read the CSV.", success, testID)
+
+			exp := [][]string{{"name", "roles"}, {"Bill", "ADMIN;USER"}, {"Ale", "USER"}}
+			got, _ := json.Marshal(records)
+			want, _ := json.Marshal(exp)
+			if !bytes.Equal(got, want) {
+				t.Fatalf("\t%s\tTest %d:\tShould write a header and a row per item : got %s, exp %s.", failed, testID, got, want)
+			}
+			t.Logf("\t%s\tTest %d:\tShould write a header and a row per item.", success, testID)
+		}
+
+		testID++
+		t.Logf("\tTest %d:\tWhen getting a list as MessagePack.", testID)
+		{
+			r := httptest.NewRequest(http.MethodGet, "/people", nil)
+			r.Header.Set("Accept", "application/msgpack")
+			w := httptest.NewRecorder()
+			app.ServeHTTP(w, r)
+
+			var got []map[string]interface{}
+			if err := msgpack.Unmarshal(w.Body.Bytes(), &got); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to decode the MessagePack : %s.", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to decode the MessagePack.", success, testID)
+
+			if len(got) != 2 || got[0]["name"] != "Bill" || len(got[0]["roles"].([]interface{})) != 2 {
+				t.Fatalf("\t%s\tTest %d:\tShould name the fields after their json tags : got %v.", failed, testID, got)
+			}
+			t.Logf("\t%s\tTest %d:\tShould name the fields after their json tags.", success, testID)
+		}
+
+		testID++
+		t.Logf("\tTest %d:\tWhen getting a list as pretty JSON.", testID)
+		{
+			w := httptest.NewRecorder()
+			app.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/people?pretty", nil))
+
+			if !strings.Contains(w.Body.String(), "\n    ") {
+				t.Fatalf("\t%s\tTest %d:\tShould indent the JSON : got %s.", failed, testID, w.Body.String())
+			}
+			t.Logf("\t%s\tTest %d:\tShould indent the JSON.", success, testID)
+		}
+	}
+}
+
+func TestCompression(t *testing.T) {
+	app := negotiationApp()
+
+	t.Log("Given the need to compress large responses.")
+
+	tt := []struct {
+		path           string
+		acceptEncoding string
+		encoding       string
+	}{
+		{path: "/many", acceptEncoding: "", encoding: ""},
+		{path: "/many", acceptEncoding: "gzip", encoding: "gzip"},
+		{path: "/many", acceptEncoding: "br", encoding: "br"},
+		{path: "/many", acceptEncoding: "gzip, br", encoding: "br"},
+		{path: "/many", acceptEncoding: "gzip;q=1, br;q=0.5", encoding: "gzip"},
+		{path: "/many", acceptEncoding: "br;q=0, gzip", encoding: "gzip"},
+		{path: "/many", acceptEncoding: "deflate, identity", encoding: ""},
+		{path: "/people", acceptEncoding: "gzip, br", encoding: ""},
+	}
+
+	for i, tc := range tt {
+		t.Logf("\tTest %d:\tWhen getting %s with Accept-Encoding %q.", i, tc.path, tc.acceptEncoding)
+		{
+			r := httptest.NewRequest(http.MethodGet, tc.path, nil)
+			if tc.acceptEncoding != "" {
+				r.Header.Set("Accept-Encoding", tc.acceptEncoding)
+			}
+			w := httptest.NewRecorder()
+			app.ServeHTTP(w, r)
+
+			if got := w.Header().Get("Content-Encoding"); got != tc.encoding {
+				t.Fatalf("\t%s\tTest %d:\tShould use the %q encoding : got %q.", failed, i, tc.encoding, got)
+			}
+			t.Logf("\t%s\tTest %d:\tShould use the %q encoding.", success, i, tc.encoding)
+
+			if !varies(w, "Accept-Encoding") {
+				t.Fatalf("\t%s\tTest %d:\tShould vary on Accept-Encoding : got %v.", failed, i, w.Header().Values("Vary"))
+			}
+			t.Logf("\t%s\tTest %d:\tShould vary on Accept-Encoding.", success, i)
+
+			var body io.Reader = w.Body
+			switch tc.encoding {
+			case "gzip":
+				zr, err := gzip.NewReader(body)
+				if err != nil {
+					t.Fatalf("\t%s\tTest %d:\tShould be able to read the gzip body : %s.", failed, i, err)
+				}
+				body = zr
+			case "br":
+				body = brotli.NewReader(body)
+			}
+
+			var got []person
+			if err := json.NewDecoder(body).Decode(&got); err != nil || len(got) == 0 {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to decode the body : %v.", failed, i, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to decode the body.", success, i)
+		}
+	}
+}
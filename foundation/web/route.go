@@ -0,0 +1,27 @@
+package web
+
+// Doc describes a route for the API documentation. Request and Response
+// hold a value of the model types read and written by the route, the status
+// code of a successful response defaults to 200. Roles lists the roles
+// allowed to call the route, none means it needs no authentication.
+type Doc struct {
+	Summary  string
+	Request  interface{}
+	Response interface{}
+	Status   int
+	Roles    []string
+}
+
+// Route is a route registered in an App.
+type Route struct {
+	Method string
+	Path   string
+	Doc    Doc
+}
+
+// Routes returns the routes registered so far, in registration order.
+func (a *App) Routes() []Route {
+	routes := make([]Route, len(a.routes))
+	copy(routes, a.routes)
+	return routes
+}
@@ -0,0 +1,74 @@
+package web_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/mohammadhsn/ultimate-service/foundation/web"
+	"go.uber.org/zap"
+)
+
+func TestUnroutedRequests(t *testing.T) {
+	t.Log("Given the need to answer requests no route handles through the middleware.")
+
+	var trail []string
+	app := web.NewApp(make(chan os.Signal, 1), zap.NewNop().Sugar(), record(&trail, "app"))
+
+	ok := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		return web.Respond(ctx, w, nil, http.StatusNoContent)
+	}
+	app.Handle(http.MethodGet, "", "/users", ok)
+	app.Handle(http.MethodPost, "", "/users", ok)
+
+	tt := []struct {
+		name   string
+		method string
+		path   string
+		status int
+		body   string
+		allow  []string
+	}{
+		{"unknown route", http.MethodGet, "/unknown", http.StatusNotFound, `{"error":"Not Found"}`, nil},
+		{"unsupported method", http.MethodDelete, "/users", http.StatusMethodNotAllowed, `{"error":"Method Not Allowed"}`, []string{"GET", "HEAD", "POST"}},
+		{"OPTIONS request", http.MethodOptions, "/users", http.StatusNoContent, "", nil},
+	}
+
+	for testId, tst := range tt {
+		t.Logf("\tTest %d:\tWhen handling a request for an %s.", testId, tst.name)
+		{
+			trail = nil
+			w := httptest.NewRecorder()
+			app.ServeHTTP(w, httptest.NewRequest(tst.method, tst.path, nil))
+
+			if w.Code != tst.status {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a %d status code : %d.", failed, testId, tst.status, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a %d status code.", success, testId, tst.status)
+
+			if body := strings.TrimSpace(w.Body.String()); body != tst.body {
+				t.Fatalf("\t%s\tTest %d:\tShould receive the body %q : %q.", failed, testId, tst.body, body)
+			}
+			if tst.body != "" && w.Header().Get("Content-Type") != "application/json" {
+				t.Fatalf("\t%s\tTest %d:\tShould receive JSON : %q.", failed, testId, w.Header().Get("Content-Type"))
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive the expected body.", success, testId)
+
+			allow := w.Header().Values("Allow")
+			sort.Strings(allow)
+			if strings.Join(allow, ",") != strings.Join(tst.allow, ",") {
+				t.Fatalf("\t%s\tTest %d:\tShould list the allowed methods %v : %v.", failed, testId, tst.allow, allow)
+			}
+			t.Logf("\t%s\tTest %d:\tShould list the allowed methods.", success, testId)
+
+			if strings.Join(trail, ",") != "app" {
+				t.Fatalf("\t%s\tTest %d:\tShould run the application middleware : %v.", failed, testId, trail)
+			}
+			t.Logf("\t%s\tTest %d:\tShould run the application middleware.", success, testId)
+		}
+	}
+}
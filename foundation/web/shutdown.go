@@ -2,6 +2,13 @@ package web
 
 import "errors"
 
+// Errors returned by a Handler fall in two categories. A shutdown error means
+// the integrity of the service is in doubt, the App signals a graceful
+// shutdown when one escapes the middleware chain. Any other error escaping
+// the chain is logged, counted and answered with a 500, it never stops the
+// service. Middleware is expected to turn expected errors into responses
+// before that happens.
+
 // shutdownError is a type used to help with the graceful termination of the service.
 type shutdownError struct {
 	Message string
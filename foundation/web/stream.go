@@ -0,0 +1,139 @@
+package web
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+)
+
+// flushEvery is the number of streamed items after which the response is
+// flushed to the client.
+const flushEvery = 100
+
+// Producer produces the items of a streamed response, handing them to send
+// one at a time. It must stop and return the error when send fails.
+type Producer func(send func(item interface{}) error) error
+
+// RespondStream writes the items of the producer to the client as they are
+// produced, as a JSON array or, if the client asks for them, as newline
+// delimited JSON or as CSV. CSV needs the items to be structs, the header row
+// is built from the first one. A client accepting none of them is answered
+// with a 406. The response is flushed regularly so memory use does not grow
+// with the number of items. Once the client goes away the context is canceled
+// and send returns the context error. If the producer fails half way, the
+// client can tell the response is incomplete: the JSON array is not closed
+// and newline delimited JSON ends with an {"error": ...} line.
+func RespondStream(ctx context.Context, w http.ResponseWriter, statusCode int, produce Producer) error {
+	var neg negotiation
+	if v, err := GetValues(ctx); err == nil {
+		neg = v.negotiation
+	}
+
+	format, ok := neg.streamFormat()
+	if !ok {
+		return Respond(ctx, w, notAcceptable(), http.StatusNotAcceptable)
+	}
+
+	contentType := "application/json"
+	switch format {
+	case formatNDJSON:
+		contentType = "application/x-ndjson"
+	case formatCSV:
+		contentType = "text/csv; charset=utf-8"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Add("Vary", "Accept")
+
+	// Write the status code to the response.
+	w.WriteHeader(statusCode)
+
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	cw := csv.NewWriter(bw)
+
+	flush := func() error {
+		cw.Flush()
+		if err := cw.Error(); err != nil {
+			return err
+		}
+		if err := bw.Flush(); err != nil {
+			return err
+		}
+		if f, ok := w.(http.Flusher); ok {
+			f.Flush()
+		}
+		return nil
+	}
+
+	if format == formatJSON {
+		bw.WriteString("[")
+	}
+
+	var fields []int
+	var row []string
+
+	var n int
+	send := func(item interface{}) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		switch format {
+		case formatCSV:
+			if n == 0 {
+				t, ok := csvStruct(item)
+				if !ok {
+					return fmt.Errorf("can not stream %T as CSV", item)
+				}
+				var header []string
+				fields, header = csvColumns(t)
+				row = make([]string, len(fields))
+				if err := cw.Write(header); err != nil {
+					return err
+				}
+			}
+			if err := csvRow(reflect.ValueOf(item), fields, row); err != nil {
+				return err
+			}
+			if err := cw.Write(row); err != nil {
+				return err
+			}
+
+		default:
+			if format == formatJSON && n > 0 {
+				bw.WriteString(",")
+			}
+			if err := enc.Encode(item); err != nil {
+				return err
+			}
+		}
+
+		n++
+		if n%flushEvery == 0 {
+			return flush()
+		}
+		return nil
+	}
+
+	if err := produce(send); err != nil {
+		if format == formatNDJSON && ctx.Err() == nil {
+			enc.Encode(struct {
+				Error string `json:"error"`
+			}{
+				Error: http.StatusText(http.StatusInternalServerError),
+			})
+		}
+		flush()
+		return err
+	}
+
+	if format == formatJSON {
+		bw.WriteString("]")
+	}
+
+	return flush()
+}
@@ -0,0 +1,224 @@
+package web_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/mohammadhsn/ultimate-service/foundation/web"
+	"go.uber.org/zap"
+)
+
+func TestRespondStream(t *testing.T) {
+	t.Log("Given the need to stream large lists to the client.")
+
+	// produce sends the numbers up to n.
+	produce := func(n int) web.Producer {
+		return func(send func(item interface{}) error) error {
+			for i := 0; i < n; i++ {
+				if err := send(i); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+	}
+
+	var streamErr error
+	app := web.NewApp(make(chan os.Signal, 1), zap.NewNop().Sugar())
+	app.Handle(http.MethodGet, "", "/numbers", func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		streamErr = web.RespondStream(ctx, w, http.StatusOK, produce(250))
+		return nil
+	})
+	app.Handle(http.MethodGet, "", "/people", func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		streamErr = web.RespondStream(ctx, w, http.StatusOK, func(send func(item interface{}) error) error {
+			for _, p := range []person{{Name: "Bill", Roles: []string{"ADMIN", "USER"}}, {Name: "Ale"}} {
+				if err := send(p); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		return nil
+	})
+	app.Handle(http.MethodGet, "", "/broken", func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		streamErr = web.RespondStream(ctx, w, http.StatusOK, func(send func(item interface{}) error) error {
+			send(1)
+			send(2)
+			return errors.New("connection reset")
+		})
+		return nil
+	})
+
+	testID := 0
+	t.Logf("\tTest %d:\tWhen streaming a JSON array.", testID)
+	{
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/numbers", nil))
+
+		var got []int
+		if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil || streamErr != nil {
+			t.Fatalf("\t%s\tTest %d:\tShould write a complete JSON array : %v, %v.", failed, testID, err, streamErr)
+		}
+		t.Logf("\t%s\tTest %d:\tShould write a complete JSON array.", success, testID)
+
+		if len(got) != 250 || got[249] != 249 {
+			t.Fatalf("\t%s\tTest %d:\tShould write every item : got %d.", failed, testID, len(got))
+		}
+		t.Logf("\t%s\tTest %d:\tShould write every item.", success, testID)
+
+		if !w.Flushed {
+			t.Fatalf("\t%s\tTest %d:\tShould flush while streaming.", failed, testID)
+		}
+		t.Logf("\t%s\tTest %d:\tShould flush while streaming.", success, testID)
+	}
+
+	testID++
+	t.Logf("\tTest %d:\tWhen streaming newline delimited JSON.", testID)
+	{
+		r := httptest.NewRequest(http.MethodGet, "/numbers", nil)
+		r.Header.Set("Accept", "application/x-ndjson")
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, r)
+
+		if got := w.Header().Get("Content-Type"); got != "application/x-ndjson" {
+			t.Fatalf("\t%s\tTest %d:\tShould respond with application/x-ndjson : got %s.", failed, testID, got)
+		}
+		t.Logf("\t%s\tTest %d:\tShould respond with application/x-ndjson.", success, testID)
+
+		lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+		if len(lines) != 250 || lines[0] != "0" || lines[249] != "249" {
+			t.Fatalf("\t%s\tTest %d:\tShould write an item per line : got %d lines.", failed, testID, len(lines))
+		}
+		t.Logf("\t%s\tTest %d:\tShould write an item per line.", success, testID)
+	}
+
+	testID++
+	t.Logf("\tTest %d:\tWhen the producer fails half way.", testID)
+	{
+		errBroken := errors.New("connection reset")
+		w := httptest.NewRecorder()
+		err := web.RespondStream(context.Background(), w, http.StatusOK, func(send func(item interface{}) error) error {
+			send(1)
+			send(2)
+			return errBroken
+		})
+
+		if !errors.Is(err, errBroken) {
+			t.Fatalf("\t%s\tTest %d:\tShould return the error of the producer : got %v.", failed, testID, err)
+		}
+		t.Logf("\t%s\tTest %d:\tShould return the error of the producer.", success, testID)
+
+		if got := w.Body.String(); !strings.HasPrefix(got, "[1") || strings.HasSuffix(got, "]") {
+			t.Fatalf("\t%s\tTest %d:\tShould flush the items and leave the array open : got %q.", failed, testID, got)
+		}
+		t.Logf("\t%s\tTest %d:\tShould flush the items and leave the array open.", success, testID)
+
+		var got []int
+		if err := json.Unmarshal(w.Body.Bytes(), &got); err == nil {
+			t.Fatalf("\t%s\tTest %d:\tShould not be a valid JSON document.", failed, testID)
+		}
+		t.Logf("\t%s\tTest %d:\tShould not be a valid JSON document.", success, testID)
+	}
+
+	testID++
+	t.Logf("\tTest %d:\tWhen the client goes away.", testID)
+	{
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		var sent int
+		w := httptest.NewRecorder()
+		err := web.RespondStream(ctx, w, http.StatusOK, func(send func(item interface{}) error) error {
+			for i := 0; ; i++ {
+				if i == 10 {
+					cancel()
+				}
+				if err := send(i); err != nil {
+					return err
+				}
+				sent++
+			}
+		})
+
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("\t%s\tTest %d:\tShould stop with the context error : got %v.", failed, testID, err)
+		}
+		t.Logf("\t%s\tTest %d:\tShould stop with the context error.", success, testID)
+
+		if sent != 10 {
+			t.Fatalf("\t%s\tTest %d:\tShould not send items once canceled : sent %d.", failed, testID, sent)
+		}
+		t.Logf("\t%s\tTest %d:\tShould not send items once canceled.", success, testID)
+	}
+
+	testID++
+	t.Logf("\tTest %d:\tWhen newline delimited JSON fails half way.", testID)
+	{
+		r := httptest.NewRequest(http.MethodGet, "/broken", nil)
+		r.Header.Set("Accept", "application/x-ndjson")
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, r)
+
+		if streamErr == nil {
+			t.Fatalf("\t%s\tTest %d:\tShould return the error of the producer.", failed, testID)
+		}
+		t.Logf("\t%s\tTest %d:\tShould return the error of the producer.", success, testID)
+
+		exp := "1\n2\n{\"error\":\"Internal Server Error\"}\n"
+		if got := w.Body.String(); got != exp {
+			t.Fatalf("\t%s\tTest %d:\tShould end with an error line : got %q.", failed, testID, got)
+		}
+		t.Logf("\t%s\tTest %d:\tShould end with an error line.", success, testID)
+	}
+
+	testID++
+	t.Logf("\tTest %d:\tWhen streaming CSV.", testID)
+	{
+		r := httptest.NewRequest(http.MethodGet, "/people", nil)
+		r.Header.Set("Accept", "text/csv")
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, r)
+
+		if got := w.Header().Get("Content-Type"); got != "text/csv; charset=utf-8" {
+			t.Fatalf("\t%s\tTest %d:\tShould respond with text/csv : got %s.", failed, testID, got)
+		}
+		t.Logf("\t%s\tTest %d:\tShould respond with text/csv.", success, testID)
+
+		exp := "name,roles\nBill,ADMIN;USER\nAle,\n"
+		if got := w.Body.String(); got != exp || streamErr != nil {
+			t.Fatalf("\t%s\tTest %d:\tShould write a header and a row per item : got %q, %v.", failed, testID, got, streamErr)
+		}
+		t.Logf("\t%s\tTest %d:\tShould write a header and a row per item.", success, testID)
+
+		r = httptest.NewRequest(http.MethodGet, "/numbers", nil)
+		r.Header.Set("Accept", "text/csv")
+		app.ServeHTTP(httptest.NewRecorder(), r)
+
+		if streamErr == nil {
+			t.Fatalf("\t%s\tTest %d:\tShould fail to stream items that are not structs.", failed, testID)
+		}
+		t.Logf("\t%s\tTest %d:\tShould fail to stream items that are not structs.", success, testID)
+	}
+
+	for _, accept := range []string{"application/msgpack", "image/png", "application/json;q=0"} {
+		testID++
+		t.Logf("\tTest %d:\tWhen streaming with Accept %q.", testID, accept)
+		{
+			r := httptest.NewRequest(http.MethodGet, "/numbers", nil)
+			r.Header.Set("Accept", accept)
+			w := httptest.NewRecorder()
+			app.ServeHTTP(w, r)
+
+			if w.Code != http.StatusNotAcceptable {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a %d status code : %d.", failed, testID, http.StatusNotAcceptable, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a %d status code.", success, testID, http.StatusNotAcceptable)
+		}
+	}
+}
@@ -11,6 +11,7 @@ import (
 	"github.com/dimfeld/httptreemux/v5"
 	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
 )
 
 // A Handler is a type that handles a http request within our own little mini framework.
@@ -23,7 +24,9 @@ type App struct {
 	mux      *httptreemux.ContextMux
 	otMux    http.Handler
 	shutdown chan os.Signal
+	log      *zap.SugaredLogger
 	mw       []Middleware
+	routes   []Route
 }
 
 func (a *App) ServeHTTP(w http.ResponseWriter, r *http.Request) {
@@ -31,34 +34,75 @@ func (a *App) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 }
 
 // NewApp created an App value that handle a set of routes for the application.
-func NewApp(shutdown chan os.Signal, mw ...Middleware) *App {
+// Requests for unknown routes, for methods a route does not support and
+// OPTIONS requests are answered through the application's middleware too.
+// The logger is used for errors that escape the middleware chain.
+func NewApp(shutdown chan os.Signal, log *zap.SugaredLogger, mw ...Middleware) *App {
 	mux := httptreemux.NewContextMux()
 
-	return &App{
+	a := App{
 		mux:      mux,
 		otMux:    otelhttp.NewHandler(mux, "request"),
 		shutdown: shutdown,
+		log:      log,
 		mw:       mw,
 	}
+
+	notFound := a.wrap("", wrapMiddleware(a.mw, statusHandler(http.StatusNotFound)))
+	methodNotAllowed := a.wrap("", wrapMiddleware(a.mw, statusHandler(http.StatusMethodNotAllowed)))
+	options := a.wrap("", wrapMiddleware(a.mw, statusHandler(http.StatusNoContent)))
+
+	mux.NotFoundHandler = notFound
+	mux.MethodNotAllowedHandler = func(w http.ResponseWriter, r *http.Request, methods map[string]httptreemux.HandlerFunc) {
+		for m := range methods {
+			w.Header().Add("Allow", m)
+		}
+		methodNotAllowed(w, r)
+	}
+	mux.OptionsHandler = func(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+		options(w, r)
+	}
+
+	return &a
 }
 
 // SignalShutdown is used to gracefully shut down the app when an integrity
-// issue is identified.
+// issue is identified. It never blocks, a shutdown already signaled is enough.
 func (a *App) SignalShutdown() {
-	a.shutdown <- syscall.SIGTERM
+	select {
+	case a.shutdown <- syscall.SIGTERM:
+	default:
+	}
 }
 
 // Handle sets a handler function for a given HTTP method and path pair
 // to the application server mux.
 func (a *App) Handle(method, group, path string, handler Handler, mw ...Middleware) {
+	finalPath := path
+	if group != "" {
+		finalPath = "/" + group + path
+	}
+
+	a.handle(method, finalPath, Doc{}, handler, mw...)
+}
+
+// handle wraps the handler with the route and application middleware and
+// registers it for the method and full path.
+func (a *App) handle(method, finalPath string, doc Doc, handler Handler, mw ...Middleware) {
 	// First wrap handler specific middleware around this handler.
 	handler = wrapMiddleware(mw, handler)
 
 	// Add the application's general middleware to the handler chain.
 	handler = wrapMiddleware(a.mw, handler)
 
-	// The function to execute for each request.
-	h := func(w http.ResponseWriter, r *http.Request) {
+	a.mux.Handle(method, finalPath, a.wrap(finalPath, handler))
+	a.routes = append(a.routes, Route{Method: method, Path: finalPath, Doc: doc})
+}
+
+// wrap returns the function to execute for each request of the route. It sets
+// up the request values and calls the fully wrapped handler.
+func (a *App) wrap(route string, handler Handler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
 
 		span := trace.SpanFromContext(ctx)
@@ -67,23 +111,57 @@ func (a *App) Handle(method, group, path string, handler Handler, mw ...Middlewa
 		// process the request.
 		v := Values{
 			TraceID: span.SpanContext().TraceID().String(),
+			Route:   route,
 			Now:     time.Now(),
+
+			negotiation: newNegotiation(r),
+			conditional: newConditional(r),
 		}
 
 		ctx = context.WithValue(ctx, key, &v)
 
-		// Call the wrapped handler functions.
+		// Record what is written to the client in the values.
+		w = &responseWriter{ResponseWriter: w, v: &v}
+
+		// Call the wrapped handler functions. Only a shutdown error stops the
+		// service. Any error escaping the chain is answered with a 500, a
+		// shutdown error too so the client is not told the request succeeded.
 		if err := handler(ctx, w, r); err != nil {
-			a.SignalShutdown()
-			return
+			shutdown := IsShutdown(err)
+			if shutdown {
+				a.log.Errorw("shutdown requested", "traceID", v.TraceID, "ERROR", err)
+			} else {
+				a.log.Errorw("unhandled error", "traceID", v.TraceID, "ERROR", err)
+			}
+
+			// Only respond if nothing has been written to the client yet.
+			if v.FirstByte.IsZero() {
+				if err := statusHandler(http.StatusInternalServerError)(ctx, w, r); err != nil {
+					a.log.Errorw("unhandled error", "traceID", v.TraceID, "ERROR", err)
+				}
+			}
+
+			if shutdown {
+				a.SignalShutdown()
+			}
 		}
-		// after
 	}
+}
 
-	finalPath := path
-	if group != "" {
-		finalPath = "/" + group + path
-	}
+// statusHandler responds with the status code and, unless there is no
+// content, its status text as a JSON error.
+func statusHandler(statusCode int) Handler {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		if statusCode == http.StatusNoContent {
+			return Respond(ctx, w, nil, statusCode)
+		}
 
-	a.mux.Handle(method, finalPath, h)
+		resp := struct {
+			Error string `json:"error"`
+		}{
+			Error: http.StatusText(statusCode),
+		}
+
+		return Respond(ctx, w, resp, statusCode)
+	}
 }
@@ -0,0 +1,174 @@
+package web_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/mohammadhsn/ultimate-service/foundation/web"
+	"go.uber.org/zap"
+)
+
+func TestHandlerErrors(t *testing.T) {
+	t.Log("Given the need to only shut down the service for shutdown errors.")
+
+	tt := []struct {
+		name     string
+		handler  web.Handler
+		mw       []web.Middleware
+		status   int
+		shutdown bool
+	}{
+		{
+			name: "plain handler error",
+			handler: func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+				return errors.New("database unavailable")
+			},
+			status: http.StatusInternalServerError,
+		},
+		{
+			name: "wrapped handler error",
+			handler: func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+				return fmt.Errorf("query: %w", errors.New("not found"))
+			},
+			status: http.StatusInternalServerError,
+		},
+		{
+			name: "plain middleware error",
+			handler: func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+				return web.Respond(ctx, w, nil, http.StatusNoContent)
+			},
+			mw: []web.Middleware{
+				func(handler web.Handler) web.Handler {
+					return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+						return errors.New("web value missing from context")
+					}
+				},
+			},
+			status: http.StatusInternalServerError,
+		},
+		{
+			name: "error after the response was written",
+			handler: func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+				if err := web.Respond(ctx, w, nil, http.StatusAccepted); err != nil {
+					return err
+				}
+				return errors.New("audit failed")
+			},
+			status: http.StatusAccepted,
+		},
+		{
+			name: "shutdown error",
+			handler: func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+				return web.NewShutdownError("integrity issue")
+			},
+			status:   http.StatusInternalServerError,
+			shutdown: true,
+		},
+		{
+			name: "shutdown error after the response was written",
+			handler: func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+				if err := web.Respond(ctx, w, nil, http.StatusAccepted); err != nil {
+					return err
+				}
+				return web.NewShutdownError("integrity issue")
+			},
+			status:   http.StatusAccepted,
+			shutdown: true,
+		},
+	}
+
+	for testId, tst := range tt {
+		t.Logf("\tTest %d:\tWhen handling a %s.", testId, tst.name)
+		{
+			shutdown := make(chan os.Signal, 1)
+			app := web.NewApp(shutdown, zap.NewNop().Sugar(), tst.mw...)
+			app.Handle(http.MethodGet, "", "/test", tst.handler)
+
+			w := httptest.NewRecorder()
+			app.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/test", nil))
+
+			if w.Code != tst.status {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a %d status code : %d.", failed, testId, tst.status, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a %d status code.", success, testId, tst.status)
+
+			var signaled bool
+			select {
+			case <-shutdown:
+				signaled = true
+			default:
+			}
+
+			if signaled != tst.shutdown {
+				t.Fatalf("\t%s\tTest %d:\tShould signal shutdown only for shutdown errors : signaled %v.", failed, testId, signaled)
+			}
+			t.Logf("\t%s\tTest %d:\tShould signal shutdown only for shutdown errors.", success, testId)
+		}
+	}
+}
+
+func TestSignalShutdownDoesNotBlock(t *testing.T) {
+	t.Log("Given the need to survive several shutdown errors at once.")
+
+	testId := 0
+	t.Logf("\tTest %d:\tWhen two requests return a shutdown error.", testId)
+	{
+		shutdown := make(chan os.Signal, 1)
+		app := web.NewApp(shutdown, zap.NewNop().Sugar())
+		app.Handle(http.MethodGet, "", "/test", func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+			return web.NewShutdownError("integrity issue")
+		})
+
+		for i := 0; i < 2; i++ {
+			app.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/test", nil))
+		}
+		t.Logf("\t%s\tTest %d:\tShould not block on the second signal.", success, testId)
+	}
+}
+
+func TestResponseRecording(t *testing.T) {
+	t.Log("Given the need to record every response in the request values.")
+
+	testId := 0
+	t.Logf("\tTest %d:\tWhen a handler writes to the response writer directly.", testId)
+	{
+		var v *web.Values
+		capture := func(handler web.Handler) web.Handler {
+			return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+				err := handler(ctx, w, r)
+				v, _ = web.GetValues(ctx)
+				return err
+			}
+		}
+
+		app := web.NewApp(make(chan os.Signal, 1), zap.NewNop().Sugar(), capture)
+		app.Handle(http.MethodGet, "", "/download", func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+			w.WriteHeader(http.StatusPartialContent)
+			w.Write([]byte("hello "))
+			w.Write([]byte("world"))
+			return nil
+		})
+
+		app.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/download", nil))
+
+		if v.StatusCode != http.StatusPartialContent {
+			t.Fatalf("\t%s\tTest %d:\tShould record the %d status code : %d.", failed, testId, http.StatusPartialContent, v.StatusCode)
+		}
+		t.Logf("\t%s\tTest %d:\tShould record the %d status code.", success, testId, http.StatusPartialContent)
+
+		if v.Size != 11 {
+			t.Fatalf("\t%s\tTest %d:\tShould record the response size : %d.", failed, testId, v.Size)
+		}
+		t.Logf("\t%s\tTest %d:\tShould record the response size.", success, testId)
+
+		if v.FirstByte.IsZero() || v.TimeToFirstByte() < 0 {
+			t.Fatalf("\t%s\tTest %d:\tShould record the time to first byte : %v.", failed, testId, v.TimeToFirstByte())
+		}
+		t.Logf("\t%s\tTest %d:\tShould record the time to first byte.", success, testId)
+	}
+}
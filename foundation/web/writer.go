@@ -0,0 +1,49 @@
+package web
+
+import (
+	"net/http"
+	"time"
+)
+
+// responseWriter wraps the http.ResponseWriter of a request and records the
+// status code, the response size and the time of the first write in the
+// request values. Handlers writing to the writer directly are covered too.
+type responseWriter struct {
+	http.ResponseWriter
+	v *Values
+}
+
+// WriteHeader implements the http.ResponseWriter interface.
+func (w *responseWriter) WriteHeader(statusCode int) {
+	if w.v.FirstByte.IsZero() {
+		w.v.StatusCode = statusCode
+		w.v.FirstByte = time.Now()
+	}
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+// Write implements the http.ResponseWriter interface.
+func (w *responseWriter) Write(b []byte) (int, error) {
+	if w.v.FirstByte.IsZero() {
+		w.WriteHeader(http.StatusOK)
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.v.Size += int64(n)
+	return n, err
+}
+
+// Flush implements the http.Flusher interface when the wrapped writer
+// supports it.
+func (w *responseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		if w.v.FirstByte.IsZero() {
+			w.WriteHeader(http.StatusOK)
+		}
+		f.Flush()
+	}
+}
+
+// Unwrap returns the wrapped writer.
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}